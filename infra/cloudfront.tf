@@ -46,6 +46,16 @@ resource "aws_cloudfront_response_headers_policy" "kittenbot_png" {
       value    = "noindex"
     }
   }
+
+  # What an image cost and how moderation scored it are for operators, not the public.
+  remove_headers_config {
+    dynamic "items" {
+      for_each = ["cost", "moderation", "moderated_by", "moderation_attempts", "moderation_score"]
+      content {
+        header = "x-amz-meta-${items.value}"
+      }
+    }
+  }
 }
 
 resource "aws_cloudfront_distribution" "kittenbot" {
@@ -85,6 +95,19 @@ resource "aws_cloudfront_distribution" "kittenbot" {
     response_headers_policy_id = aws_cloudfront_response_headers_policy.kittenbot_png.id
   }
 
+  # S3 answers 403 for missing objects when the bucket cannot be listed.
+  custom_error_response {
+    error_code         = 403
+    response_code      = 404
+    response_page_path = "/404.html"
+  }
+
+  custom_error_response {
+    error_code         = 404
+    response_code      = 404
+    response_page_path = "/404.html"
+  }
+
   restrictions {
     geo_restriction {
       restriction_type = "none"
@@ -173,6 +173,7 @@ data "aws_iam_policy_document" "lambda" {
 
   statement {
     actions = [
+      "s3:DeleteObject",
       "s3:GetObject",
       "s3:PutObject"
     ]
@@ -185,7 +186,10 @@ data "aws_iam_policy_document" "lambda" {
   }
 
   statement {
-    actions   = ["cloudfront:CreateInvalidation"]
+    actions = [
+      "cloudfront:CreateInvalidation",
+      "cloudfront:GetInvalidation"
+    ]
     resources = [aws_cloudfront_distribution.kittenbot.arn]
   }
 }
@@ -25,4 +25,24 @@ data "aws_iam_policy_document" "kittenbot_bucket" {
       variable = "aws:SourceArn"
     }
   }
+
+  # Staged images, scheduler locks, the cost ledger and publish backups share the bucket but are not part of
+  # the site. CloudFront answers 403, which it serves as the 404 page.
+  statement {
+    sid    = "CloudFrontDenyPrivateState"
+    effect = "Deny"
+
+    principals {
+      type        = "Service"
+      identifiers = ["cloudfront.amazonaws.com"]
+    }
+
+    actions = ["s3:GetObject"]
+    resources = [
+      "${aws_s3_bucket.kittenbot.arn}/pending/*",
+      "${aws_s3_bucket.kittenbot.arn}/locks/*",
+      "${aws_s3_bucket.kittenbot.arn}/ledger/*",
+      "${aws_s3_bucket.kittenbot.arn}/rollback/*",
+    ]
+  }
 }
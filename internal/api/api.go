@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/index"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+// Day is published as YYYYMMDD.json and, for the latest day, api/latest.json. Image is the
+// pick of the day; Pick is its variant when the day has more than one image.
+type Day struct {
+	Date   string  `json:"date"`
+	Page   string  `json:"page"`
+	Image  string  `json:"image"`
+	Pick   int     `json:"pick,omitempty"`
+	Images []Image `json:"images"`
+}
+
+// Image is what the API makes public about one of a day's images: how to reproduce it and
+// how to check a copy of it. The rest of the image's object metadata, such as what it cost
+// and how moderation scored it, is for operators only and left out.
+type Image struct {
+	URL     string `json:"url"`
+	Variant int    `json:"variant,omitempty"`
+	Model   string `json:"model"`
+	Prompt  string `json:"prompt"`
+	Seed    string `json:"seed"`
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	SHA256  string `json:"sha256"`
+	DHash   string `json:"dhash,omitempty"`
+}
+
+// Index is published as api/index.json, listing every day oldest first.
+type Index struct {
+	Updated time.Time `json:"updated"`
+	Days    []Entry   `json:"days"`
+}
+
+// Entry summarises a day in the index; URL is its YYYYMMDD.json, left out for days published
+// before the API was.
+type Entry struct {
+	Date   string `json:"date"`
+	URL    string `json:"url,omitempty"`
+	Page   string `json:"page"`
+	Image  string `json:"image"`
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	Seed   string `json:"seed"`
+}
+
+// Generator builds the JSON documents of the site's read API.
+type Generator struct {
+	reader store.Reader
+	now    clock.Clock
+	site   string
+}
+
+func NewGenerator(i *do.Injector) (*Generator, error) {
+	reader, err := do.Invoke[store.Reader](i)
+	if err != nil {
+		return nil, err
+	}
+	now := do.MustInvoke[clock.Clock](i)
+	site := strings.TrimSuffix(do.MustInvokeNamed[string](i, "site_url"), "/")
+	return &Generator{reader, now, site}, nil
+}
+
+// Day describes a day from the uploads of its images; pick counts from 1 and is 0 when the
+// day has a single image.
+func (g *Generator) Day(date string, pick int, images []store.UploadParams) ([]byte, error) {
+	d := Day{
+		Date: date,
+		Page: g.url(date + ".html"),
+		Pick: pick,
+		Images: lo.Map(images, func(u store.UploadParams, _ int) Image {
+			sum := sha256.Sum256(u.Data)
+			img := Image{
+				URL:    g.url(u.Name),
+				Model:  u.Metadata["model"],
+				Prompt: u.Metadata["prompt"],
+				Seed:   u.Metadata["seed"],
+				SHA256: hex.EncodeToString(sum[:]),
+				DHash:  u.Metadata["dhash"],
+			}
+			img.Variant, _ = strconv.Atoi(u.Metadata["variant"])
+			img.Width, _ = strconv.Atoi(u.Metadata["width"])
+			img.Height, _ = strconv.Atoi(u.Metadata["height"])
+			return img
+		}),
+	}
+	d.Image = d.Images[max(pick, 1)-1].URL
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Index describes the published days, linking the JSON of those that have one.
+func (g *Generator) Index(ctx context.Context, days []index.Day) ([]byte, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("api")
+	log.Info("generating api index", "days", len(days))
+
+	names, err := g.reader.List(ctx, store.ListParams{Delimiter: "/"})
+	if err != nil {
+		return nil, err
+	}
+	documented := lo.SliceToMap(lo.FilterMap(names, func(name string, _ int) (string, bool) {
+		return day.FromName(name, ".json")
+	}), func(key string) (string, bool) { return key, true })
+
+	idx := Index{
+		Updated: g.now().UTC(),
+		Days: lo.Map(days, func(d index.Day, _ int) Entry {
+			return Entry{
+				Date:   d.Key,
+				URL:    lo.Ternary(documented[d.Key], g.url(d.Key+".json"), ""),
+				Page:   g.url(d.Page()),
+				Image:  g.url(d.Image()),
+				Model:  d.Metadata["model"],
+				Prompt: d.Metadata["prompt"],
+				Seed:   d.Metadata["seed"],
+			}
+		}),
+	}
+	return json.MarshalIndent(idx, "", "  ")
+}
+
+func (g *Generator) url(name string) string {
+	return g.site + "/" + name
+}
@@ -0,0 +1,94 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/api"
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/index"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+)
+
+var now = time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC)
+
+func generator(t *testing.T, names ...string) *api.Generator {
+	t.Helper()
+	s := fake.NewStore(func() time.Time { return now })
+	for _, name := range names {
+		s.Put(store.UploadParams{Name: name})
+	}
+	i := do.New()
+	do.ProvideValue[store.Reader](i, s)
+	do.ProvideValue[clock.Clock](i, func() time.Time { return now })
+	do.ProvideNamedValue[string](i, "site_url", "https://kittenbot.io/")
+	g, err := api.NewGenerator(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestDay(t *testing.T) {
+	data, err := generator(t).Day("20231201", 2, []store.UploadParams{
+		{Name: "20231201-1.png", Data: []byte("one"), Metadata: map[string]string{"model": "icbinp", "seed": "1", "variant": "1"}},
+		{Name: "20231201-2.png", Data: []byte("two"), Metadata: map[string]string{"model": "icbinp", "seed": "2", "variant": "2", "width": "512", "height": "768", "dhash": "6e625a5252c24549", "cost": "0.0102", "moderation_score": "0.120"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, private := range []string{"cost", "moderation_score", "metadata"} {
+		if bytes.Contains(data, []byte(private)) {
+			t.Errorf("published %s:\n%s", private, data)
+		}
+	}
+
+	var d api.Day
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Page != "https://kittenbot.io/20231201.html" || d.Image != "https://kittenbot.io/20231201-2.png" {
+		t.Errorf("got page %s and image %s", d.Page, d.Image)
+	}
+	img := d.Images[1]
+	if img.Variant != 2 || img.Seed != "2" || img.Width != 512 || img.Height != 768 || img.DHash != "6e625a5252c24549" {
+		t.Errorf("got image %+v", img)
+	}
+	if want := "3fc4ccfe745870e2c0d99f71f30ff0656c8dedd41cc1d7d3d376b0dbe685e2f3"; img.SHA256 != want {
+		t.Errorf("got sha256 %s, want %s", img.SHA256, want)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	days := []index.Day{
+		{Key: "20231130", Metadata: map[string]string{"model": "icbinp", "prompt": "old kitten", "seed": "42"}},
+		{Key: "20231201", Metadata: map[string]string{"variants": "2", "pick": "2"}},
+	}
+	data, err := generator(t, "20231130.html", "20231201.html", "20231201.json").Index(context.Background(), days)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var idx api.Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		t.Fatal(err)
+	}
+	if !idx.Updated.Equal(now) || len(idx.Days) != 2 {
+		t.Fatalf("got %+v", idx)
+	}
+	if got := idx.Days[0]; got.URL != "" || got.Prompt != "old kitten" {
+		t.Errorf("got %+v, want no url for a day without its json", got)
+	}
+	if got := idx.Days[1].URL; got != "https://kittenbot.io/20231201.json" {
+		t.Errorf("got url %s", got)
+	}
+	if got := idx.Days[1].Image; got != "https://kittenbot.io/20231201-2.png" {
+		t.Errorf("got image %s", got)
+	}
+}
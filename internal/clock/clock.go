@@ -0,0 +1,6 @@
+package clock
+
+import "time"
+
+// Clock reports the current time. It is injected so tests can pin "now".
+type Clock func() time.Time
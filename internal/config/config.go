@@ -0,0 +1,430 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/robfig/cron/v3"
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is every setting kittenbot reads at startup. Each field is named by its config
+// tag, which is also its key in a config file; the environment variable is the upper-cased
+// name and the flag is the name with dashes. Later sources win: defaults, file, environment,
+// then flags.
+type Config struct {
+	Bucket       string `config:"bucket" usage:"S3 bucket the site is served from"`
+	SiteURL      string `config:"site_url" usage:"public URL of the site"`
+	Timezone     string `config:"timezone" usage:"timezone that decides which day it is on the site"`
+	FirstDay     string `config:"first_day" usage:"first day of the site as YYYYMMDD, runs for earlier days are rejected"`
+	StorageClass string `config:"storage_class" usage:"S3 storage class for uploaded objects"`
+
+	SiteTitle       string `config:"site_title" usage:"title of the site shown on its pages"`
+	SiteDescription string `config:"site_description" usage:"description of the site shown on its pages"`
+	ThemeDir        string `config:"theme_dir" usage:"directory of templates overriding the built-in theme"`
+
+	CDN                  string        `config:"cdn" usage:"CDN to invalidate: cloudfront, cloudflare, fastly or none"`
+	Distribution         string        `config:"distribution" usage:"CloudFront distribution ID"`
+	InvalidationWait     time.Duration `config:"invalidation_wait" usage:"how long to wait for CloudFront invalidations to finish, 0 to not wait"`
+	CloudflareZone       string        `config:"cloudflare_zone" usage:"Cloudflare zone ID"`
+	CloudflareTokenParam string        `config:"cloudflare_token_param" usage:"parameter holding the Cloudflare API token"`
+	CloudflareURL        string        `config:"cloudflare_url" usage:"base URL of the Cloudflare API"`
+	FastlyKeyParam       string        `config:"fastly_key_param" usage:"parameter holding the Fastly API key"`
+	FastlyURL            string        `config:"fastly_url" usage:"base URL of the Fastly API"`
+
+	ParamSources   string        `config:"param_sources" usage:"comma separated parameter sources: ssm, secretsmanager, env, dotenv=<file>, file=<file>"`
+	ParamRecursive bool          `config:"param_recursive" usage:"fetch parameter lists recursively"`
+	SecretsTTL     time.Duration `config:"secrets_ttl" usage:"how long to cache Secrets Manager secrets"`
+
+	DezgoKeyParam           string  `config:"dezgo_key_param" usage:"parameter holding the Dezgo API key"`
+	PromptsParam            string  `config:"prompts_param" usage:"parameter path holding model|prompt pairs"`
+	Variants                int     `config:"variants" usage:"kittens to generate each day when a run does not list its variants"`
+	Budget                  float64 `config:"budget" usage:"credits that may be spent generating images each month, 0 for no limit"`
+	BalanceWarning          float64 `config:"balance_warning" usage:"credit balance below which a warning is logged, 0 to not warn"`
+	Subreddit               string  `config:"subreddit" usage:"subreddit to post to"`
+	RedditClientIDParam     string  `config:"reddit_client_id_param" usage:"parameter holding the Reddit client ID"`
+	RedditClientSecretParam string  `config:"reddit_client_secret_param" usage:"parameter holding the Reddit client secret"`
+	RedditUsernameParam     string  `config:"reddit_username_param" usage:"parameter holding the Reddit username"`
+	RedditPasswordParam     string  `config:"reddit_password_param" usage:"parameter holding the Reddit password"`
+
+	Moderation               string  `config:"moderation" usage:"comma separated image moderators: classifier, blocklist, dedup or none"`
+	ModerationURL            string  `config:"moderation_url" usage:"URL of the NSFW classifier images are posted to"`
+	ModerationThreshold      float64 `config:"moderation_threshold" usage:"classifier score at or above which an image is rejected"`
+	ModerationBlocklistParam string  `config:"moderation_blocklist_param" usage:"parameter path holding SHA-256 hashes of blocked images"`
+	ModerationAttempts       int     `config:"moderation_attempts" usage:"how many images to generate before giving up when moderation rejects them"`
+	DedupDays                int     `config:"dedup_days" usage:"how many days of images dedup compares against"`
+	DedupDistance            int     `config:"dedup_distance" usage:"Hamming distance between perceptual hashes at or below which dedup rejects an image"`
+	Staged                   bool    `config:"staged" usage:"hold generated images under pending/ until they are approved"`
+	VerifyDistance           int     `config:"verify_distance" usage:"Hamming distance between perceptual hashes at or below which verify counts an image as reproduced"`
+
+	Notify                    string `config:"notify" usage:"comma separated notifiers told when a run fails: smtp, webhook, discord, slack or none"`
+	NotifySuccess             bool   `config:"notify_success" usage:"also notify when a run succeeds"`
+	NotifyWebhookURL          string `config:"notify_webhook_url" usage:"URL events are posted to as JSON"`
+	NotifyDiscordWebhookParam string `config:"notify_discord_webhook_param" usage:"parameter holding the Discord webhook URL"`
+	NotifySlackWebhookParam   string `config:"notify_slack_webhook_param" usage:"parameter holding the Slack incoming webhook URL"`
+	NotifySMTPAddr            string `config:"notify_smtp_addr" usage:"host:port of the SMTP server notifications are sent through"`
+	NotifySMTPFrom            string `config:"notify_smtp_from" usage:"address notification emails are sent from"`
+	NotifySMTPTo              string `config:"notify_smtp_to" usage:"comma separated addresses notification emails are sent to"`
+	NotifySMTPUsername        string `config:"notify_smtp_username" usage:"SMTP username, empty to send without authenticating"`
+	NotifySMTPPasswordParam   string `config:"notify_smtp_password_param" usage:"parameter holding the SMTP password"`
+
+	Listen          string `config:"listen" usage:"address to serve the admin API on, e.g. :8080"`
+	AdminTokenParam string `config:"admin_token_param" usage:"parameter holding the admin API bearer token"`
+
+	Schedule         string        `config:"schedule" usage:"cron expression to run on when not in Lambda, e.g. 30 0 * * *"`
+	ScheduleTimezone string        `config:"schedule_timezone" usage:"timezone the schedule is evaluated in, defaults to timezone"`
+	ScheduleCatchUp  time.Duration `config:"schedule_catch_up" usage:"how far back to look for a missed run on startup, 0 to not catch up"`
+}
+
+func defaults() Config {
+	return Config{
+		SiteURL:      "https://kittenbot.io",
+		Timezone:     "UTC",
+		FirstDay:     "20230720",
+		StorageClass: "INTELLIGENT_TIERING",
+		CDN:          "cloudfront",
+		ParamSources: "ssm",
+		SecretsTTL:   5 * time.Minute,
+		Variants:     1,
+
+		CloudflareURL: "https://api.cloudflare.com/client/v4",
+		FastlyURL:     "https://api.fastly.com",
+
+		SiteTitle:       "KittenBot",
+		SiteDescription: "Daily AI Generated Kittens",
+
+		Moderation:          "none",
+		ModerationThreshold: 0.8,
+		ModerationAttempts:  3,
+		DedupDays:           30,
+		DedupDistance:       4,
+		VerifyDistance:      4,
+
+		Notify: "none",
+
+		ScheduleCatchUp: 24 * time.Hour,
+	}
+}
+
+// Error reports every missing or invalid setting at once.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Load builds a Config from a config file named by -config or CONFIG, the environment and
+// command line flags, then validates it. The arguments left after the flags are returned.
+func Load(args []string) (*Config, []string, error) {
+	cfg := defaults()
+	fields := fieldsOf(&cfg)
+
+	fs := flag.NewFlagSet("kittenbot", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG"), "YAML or JSON config file")
+	flags := make(map[string]*string, len(fields))
+	for _, f := range fields {
+		flags[f.name] = fs.String(strings.ReplaceAll(f.name, "_", "-"), "", f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	var problems []string
+	if *file != "" {
+		if err := loadFile(*file, fields); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(strings.ToUpper(f.name)); ok {
+			if err := f.set(v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", strings.ToUpper(f.name), err))
+			}
+		}
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		name := strings.ReplaceAll(fl.Name, "-", "_")
+		f, ok := lo.Find(fields, func(f field) bool { return f.name == name })
+		if !ok {
+			return
+		}
+		if err := f.set(*flags[name]); err != nil {
+			problems = append(problems, fmt.Sprintf("-%s: %v", fl.Name, err))
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		var cerr *Error
+		if errors.As(err, &cerr) {
+			problems = append(problems, cerr.Problems...)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, nil, &Error{problems}
+	}
+	return &cfg, fs.Args(), nil
+}
+
+// Validate checks required settings, including those only required by the chosen CDN.
+// Reddit settings are only required here when a schedule will post; see ValidatePosting.
+func (c *Config) Validate() error {
+	var problems []string
+	required := func(name, value string) {
+		if value == "" {
+			problems = append(problems, fmt.Sprintf("%s is required", name))
+		}
+	}
+
+	required("bucket", c.Bucket)
+	required("dezgo_key_param", c.DezgoKeyParam)
+	required("prompts_param", c.PromptsParam)
+	if c.Schedule != "" {
+		problems = append(problems, c.postingProblems()...)
+	}
+
+	if c.Listen != "" {
+		required("admin_token_param", c.AdminTokenParam)
+	}
+
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		problems = append(problems, fmt.Sprintf("timezone %q: %v", c.Timezone, err))
+	}
+	if !day.IsKey(c.FirstDay) {
+		problems = append(problems, fmt.Sprintf("first_day %q must be formatted as YYYYMMDD", c.FirstDay))
+	}
+	scheduleTimezone := lo.Ternary(c.ScheduleTimezone != "", c.ScheduleTimezone, c.Timezone)
+	if _, err := time.LoadLocation(scheduleTimezone); err != nil {
+		problems = append(problems, fmt.Sprintf("schedule_timezone %q: %v", scheduleTimezone, err))
+	} else if c.Schedule != "" {
+		if _, err := cron.ParseStandard("CRON_TZ=" + scheduleTimezone + " " + c.Schedule); err != nil {
+			problems = append(problems, fmt.Sprintf("schedule %q: %v", c.Schedule, err))
+		}
+	}
+
+	if u, err := url.Parse(c.SiteURL); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("site_url %q must be an absolute URL", c.SiteURL))
+	}
+	if c.ThemeDir != "" {
+		if info, err := os.Stat(c.ThemeDir); err != nil {
+			problems = append(problems, fmt.Sprintf("theme_dir: %v", err))
+		} else if !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("theme_dir %q must be a directory", c.ThemeDir))
+		}
+	}
+
+	switch c.CDN {
+	case "cloudfront":
+		required("distribution", c.Distribution)
+	case "cloudflare":
+		required("cloudflare_zone", c.CloudflareZone)
+		required("cloudflare_token_param", c.CloudflareTokenParam)
+	case "fastly":
+		required("fastly_key_param", c.FastlyKeyParam)
+	case "none":
+	default:
+		problems = append(problems, fmt.Sprintf("cdn %q must be one of cloudfront, cloudflare, fastly or none", c.CDN))
+	}
+
+	storageClasses := []string{"STANDARD", "STANDARD_IA", "ONEZONE_IA", "INTELLIGENT_TIERING", "GLACIER_IR"}
+	if !lo.Contains(storageClasses, c.StorageClass) {
+		problems = append(problems, fmt.Sprintf("storage_class %q must be one of %s", c.StorageClass, strings.Join(storageClasses, ", ")))
+	}
+
+	for _, source := range strings.Split(c.ParamSources, ",") {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(source), "=")
+		switch kind {
+		case "ssm", "secretsmanager", "env", "dotenv":
+		case "file":
+			required("param_sources file path", arg)
+		default:
+			problems = append(problems, fmt.Sprintf("param_sources entry %q must be one of ssm, secretsmanager, env, dotenv or file", source))
+		}
+	}
+
+	for _, kind := range strings.Split(c.Moderation, ",") {
+		switch strings.TrimSpace(kind) {
+		case "classifier":
+			required("moderation_url", c.ModerationURL)
+		case "blocklist":
+			required("moderation_blocklist_param", c.ModerationBlocklistParam)
+		case "dedup", "none":
+		default:
+			problems = append(problems, fmt.Sprintf("moderation entry %q must be one of classifier, blocklist, dedup or none", kind))
+		}
+	}
+	for _, kind := range strings.Split(c.Notify, ",") {
+		switch strings.TrimSpace(kind) {
+		case "smtp":
+			required("notify_smtp_addr", c.NotifySMTPAddr)
+			required("notify_smtp_from", c.NotifySMTPFrom)
+			required("notify_smtp_to", c.NotifySMTPTo)
+			if c.NotifySMTPUsername != "" {
+				required("notify_smtp_password_param", c.NotifySMTPPasswordParam)
+			}
+		case "webhook":
+			if u, err := url.Parse(c.NotifyWebhookURL); err != nil || u.Scheme == "" || u.Host == "" {
+				problems = append(problems, fmt.Sprintf("notify_webhook_url %q must be an absolute URL", c.NotifyWebhookURL))
+			}
+		case "discord":
+			required("notify_discord_webhook_param", c.NotifyDiscordWebhookParam)
+		case "slack":
+			required("notify_slack_webhook_param", c.NotifySlackWebhookParam)
+		case "none":
+		default:
+			problems = append(problems, fmt.Sprintf("notify entry %q must be one of smtp, webhook, discord, slack or none", kind))
+		}
+	}
+	if c.ModerationThreshold <= 0 || c.ModerationThreshold > 1 {
+		problems = append(problems, "moderation_threshold must be greater than 0 and at most 1")
+	}
+	if c.ModerationAttempts < 1 {
+		problems = append(problems, "moderation_attempts must be at least 1")
+	}
+	if c.DedupDays < 1 {
+		problems = append(problems, "dedup_days must be at least 1")
+	}
+	if c.DedupDistance < 0 || c.DedupDistance > 64 {
+		problems = append(problems, "dedup_distance must be between 0 and 64")
+	}
+	if c.Budget < 0 {
+		problems = append(problems, "budget must not be negative")
+	}
+	if c.BalanceWarning < 0 {
+		problems = append(problems, "balance_warning must not be negative")
+	}
+	if c.VerifyDistance < 0 || c.VerifyDistance > 64 {
+		problems = append(problems, "verify_distance must be between 0 and 64")
+	}
+
+	if c.InvalidationWait < 0 {
+		problems = append(problems, "invalidation_wait must not be negative")
+	}
+	if c.ScheduleCatchUp < 0 {
+		problems = append(problems, "schedule_catch_up must not be negative")
+	}
+	if c.Variants < 1 {
+		problems = append(problems, "variants must be at least 1")
+	}
+	if c.SecretsTTL < 0 {
+		problems = append(problems, "secrets_ttl must not be negative")
+	}
+
+	if len(problems) > 0 {
+		return &Error{problems}
+	}
+	return nil
+}
+
+// ValidatePosting checks the settings needed to post to Reddit. Commands that never post,
+// like verify, pending or runs without the post phase, work without them, so they are
+// checked when a run first reaches the post phase rather than on startup.
+func (c *Config) ValidatePosting() error {
+	if problems := c.postingProblems(); len(problems) > 0 {
+		return &Error{problems}
+	}
+	return nil
+}
+
+func (c *Config) postingProblems() []string {
+	var problems []string
+	for _, s := range []struct{ name, value string }{
+		{"subreddit", c.Subreddit},
+		{"reddit_client_id_param", c.RedditClientIDParam},
+		{"reddit_client_secret_param", c.RedditClientSecretParam},
+		{"reddit_username_param", c.RedditUsernameParam},
+		{"reddit_password_param", c.RedditPasswordParam},
+	} {
+		if s.value == "" {
+			problems = append(problems, fmt.Sprintf("%s is required to post", s.name))
+		}
+	}
+	return problems
+}
+
+type field struct {
+	name  string
+	usage string
+	value reflect.Value
+}
+
+func fieldsOf(cfg *Config) []field {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		fields = append(fields, field{
+			name:  t.Field(i).Tag.Get("config"),
+			usage: t.Field(i).Tag.Get("usage"),
+			value: v.Field(i),
+		})
+	}
+	return fields
+}
+
+func (f field) set(s string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(s)
+	case int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(n))
+	case float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetFloat(n)
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+	return nil
+}
+
+func loadFile(path string, fields []field) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// JSON is a subset of YAML so one decoder covers both.
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var errs []error
+	for _, f := range fields {
+		if v, ok := values[f.name]; ok {
+			if err := f.set(fmt.Sprint(v)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", path, f.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
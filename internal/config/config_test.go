@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// required are the settings every configuration needs, as flags.
+var required = []string{"-bucket", "kittenbot", "-dezgo-key-param", "/dezgo", "-prompts-param", "/prompts", "-distribution", "E123"}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", "site_title: from file\nsite_description: from file\ntimezone: Europe/Berlin\nvariants: 2\n")
+	t.Setenv("SITE_DESCRIPTION", "from env")
+	t.Setenv("TIMEZONE", "America/New_York")
+	args := append([]string{"-config", file, "-timezone", "Asia/Tokyo"}, append(required, "approve", "20231201")...)
+
+	cfg, rest, err := Load(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ name, got, want string }{
+		{"storage_class", cfg.StorageClass, "INTELLIGENT_TIERING"},
+		{"site_title", cfg.SiteTitle, "from file"},
+		{"site_description", cfg.SiteDescription, "from env"},
+		{"timezone", cfg.Timezone, "Asia/Tokyo"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("got %s %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if cfg.Variants != 2 {
+		t.Errorf("got variants %d, want 2 from the file", cfg.Variants)
+	}
+	if strings.Join(rest, " ") != "approve 20231201" {
+		t.Errorf("got args %q", rest)
+	}
+}
+
+func TestLoadTypes(t *testing.T) {
+	file := writeFile(t, "config.json", `{"budget": 2.5, "staged": true, "invalidation_wait": "90s"}`)
+	t.Setenv("DEDUP_DAYS", "7")
+	t.Setenv("PARAM_RECURSIVE", "1")
+	args := append([]string{"-config", file, "-schedule-catch-up", "2h", "-moderation-threshold", "0.5"}, required...)
+
+	cfg, _, err := Load(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Budget != 2.5 || !cfg.Staged || cfg.InvalidationWait != 90*time.Second {
+		t.Errorf("got budget %g, staged %v, invalidation_wait %s from the file", cfg.Budget, cfg.Staged, cfg.InvalidationWait)
+	}
+	if cfg.DedupDays != 7 || !cfg.ParamRecursive {
+		t.Errorf("got dedup_days %d, param_recursive %v from the environment", cfg.DedupDays, cfg.ParamRecursive)
+	}
+	if cfg.ScheduleCatchUp != 2*time.Hour || cfg.ModerationThreshold != 0.5 {
+		t.Errorf("got schedule_catch_up %s, moderation_threshold %g from flags", cfg.ScheduleCatchUp, cfg.ModerationThreshold)
+	}
+}
+
+func TestLoadAggregatesErrors(t *testing.T) {
+	file := writeFile(t, "config.yaml", "variants: many\n")
+	t.Setenv("BUDGET", "lots")
+	_, _, err := Load([]string{"-config", file, "-invalidation-wait", "soon", "-cdn", "akamai", "-first-day", "July"})
+
+	var cerr *Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("got %v, want a configuration error", err)
+	}
+	for _, want := range []string{
+		file + ": variants:",
+		"BUDGET:",
+		"-invalidation-wait:",
+		"bucket is required",
+		"dezgo_key_param is required",
+		`cdn "akamai" must be one of`,
+		`first_day "July" must be formatted as YYYYMMDD`,
+	} {
+		if !lo.ContainsBy(cerr.Problems, func(p string) bool { return strings.HasPrefix(p, want) }) {
+			t.Errorf("problems are missing %q:\n%s", want, err)
+		}
+	}
+}
+
+func TestValidatePosting(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "without a schedule", args: required},
+		{name: "with a schedule", args: append([]string{"-schedule", "30 0 * * *"}, required...), wantErr: true},
+		{
+			name: "with a schedule and reddit",
+			args: append([]string{
+				"-schedule", "30 0 * * *",
+				"-subreddit", "kittens",
+				"-reddit-client-id-param", "/id",
+				"-reddit-client-secret-param", "/secret",
+				"-reddit-username-param", "/username",
+				"-reddit-password-param", "/password",
+			}, required...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := Load(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), "subreddit is required to post") {
+					t.Errorf("got %v, want the missing reddit settings", err)
+				}
+				return
+			}
+			if err := cfg.ValidatePosting(); (err != nil) != (cfg.Subreddit == "") {
+				t.Errorf("ValidatePosting got %v", err)
+			}
+		})
+	}
+}
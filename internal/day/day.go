@@ -0,0 +1,90 @@
+package day
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the layout of the keys naming each day's objects, e.g. 20231201.png.
+const Format = "20060102"
+
+// Calendar converts between instants and day keys in the site's timezone, so "today"
+// means the same thing to the handler, the feed and the pages.
+type Calendar struct {
+	loc *time.Location
+}
+
+func NewCalendar(loc *time.Location) *Calendar {
+	return &Calendar{loc}
+}
+
+func (c *Calendar) Location() *time.Location {
+	return c.loc
+}
+
+// Key returns the day key t falls on.
+func (c *Calendar) Key(t time.Time) string {
+	return t.In(c.loc).Format(Format)
+}
+
+// Parse returns midnight at the start of the day named by key.
+func (c *Calendar) Parse(key string) (time.Time, error) {
+	return time.ParseInLocation(Format, key, c.loc)
+}
+
+func (c *Calendar) In(t time.Time) time.Time {
+	return t.In(c.loc)
+}
+
+// FromName returns the day key of an object named like 20231201.png, if it is one.
+func FromName(name, ext string) (string, bool) {
+	key, ok := strings.CutSuffix(name, ext)
+	if !ok || !IsKey(key) {
+		return "", false
+	}
+	return key, true
+}
+
+// Name returns the object name of one of a day's images. Variant 0 is a day with a single
+// image, e.g. 20231201.png; variants from 1 are numbered, e.g. 20231201-2.png.
+func Name(key string, variant int, ext string) string {
+	if variant == 0 {
+		return key + ext
+	}
+	return key + "-" + strconv.Itoa(variant) + ext
+}
+
+// ParseName is the inverse of Name.
+func ParseName(name, ext string) (string, int, bool) {
+	stem, ok := strings.CutSuffix(name, ext)
+	if !ok {
+		return "", 0, false
+	}
+	key, suffix, numbered := strings.Cut(stem, "-")
+	if !IsKey(key) {
+		return "", 0, false
+	}
+	if !numbered {
+		return key, 0, true
+	}
+	variant, err := strconv.Atoi(suffix)
+	if err != nil || variant < 1 || strconv.Itoa(variant) != suffix {
+		return "", 0, false
+	}
+	return key, variant, true
+}
+
+// IsKey reports whether s is a well formed day key.
+func IsKey(s string) bool {
+	if len(s) != len(Format) {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	_, err := time.Parse(Format, s)
+	return err == nil
+}
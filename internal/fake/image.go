@@ -0,0 +1,74 @@
+package fake
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"image"
+	"image/png"
+	"sync"
+
+	kbimage "github.com/dmorgan81/kittenbot/internal/image"
+)
+
+// Generator returns a small PNG derived from the prompt and seed, so the same params
+// always produce the same bytes. An empty seed is replaced with Seed. Cost and Balance are
+// reported with each image when they are not 0.
+type Generator struct {
+	mu      sync.Mutex
+	Seed    string
+	Cost    float64
+	Balance float64
+	Err     error
+	Calls   []kbimage.Params
+	models  []string
+}
+
+func NewGenerator(models ...string) *Generator {
+	return &Generator{Seed: "1234", models: models}
+}
+
+func (g *Generator) Models() []string {
+	return g.models
+}
+
+func (g *Generator) Generate(_ context.Context, params kbimage.Params) (kbimage.Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.Calls = append(g.Calls, params)
+	if g.Err != nil {
+		return kbimage.Result{}, g.Err
+	}
+
+	result := kbimage.Result{Seed: params.Seed}
+	if result.Seed == "" {
+		result.Seed = g.Seed
+	}
+	if cost := g.Cost; cost != 0 {
+		result.Cost = &cost
+	}
+	if balance := g.Balance; balance != 0 {
+		result.Balance = &balance
+	}
+	var err error
+	result.Data, err = Image(params.Prompt + result.Seed)
+	return result, err
+}
+
+// Image renders a 16x16 PNG whose pixels are derived from key. Different keys give
+// unrelated pixels, so their perceptual hashes are far apart.
+func Image(key string) ([]byte, error) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		h.Write([]byte{byte(i)})
+		img.Pix[i] = uint8(h.Sum64())
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
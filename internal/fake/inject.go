@@ -0,0 +1,148 @@
+package fake
+
+import (
+	"context"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/api"
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/config"
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/feed"
+	"github.com/dmorgan81/kittenbot/internal/handler"
+	"github.com/dmorgan81/kittenbot/internal/image"
+	"github.com/dmorgan81/kittenbot/internal/index"
+	"github.com/dmorgan81/kittenbot/internal/ledger"
+	"github.com/dmorgan81/kittenbot/internal/lock"
+	"github.com/dmorgan81/kittenbot/internal/moderate"
+	"github.com/dmorgan81/kittenbot/internal/notify"
+	"github.com/dmorgan81/kittenbot/internal/page"
+	"github.com/dmorgan81/kittenbot/internal/param"
+	"github.com/dmorgan81/kittenbot/internal/post"
+	"github.com/dmorgan81/kittenbot/internal/prompt"
+	"github.com/dmorgan81/kittenbot/internal/sitemap"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/dmorgan81/kittenbot/internal/verify"
+	"github.com/samber/do"
+)
+
+// Injector wires fakes in place of every external service and the real implementations of
+// everything else. Fields can be changed before calling Build.
+type Injector struct {
+	Config      *config.Config
+	Now         time.Time
+	Fetcher     Fetcher
+	Generator   *Generator
+	Store       *Store
+	Invalidator *Invalidator
+	Poster      *Poster
+	Notifier    *Notifier
+}
+
+func NewInjector(now time.Time) *Injector {
+	f := &Injector{
+		Config: &config.Config{
+			Bucket:       "kittenbot",
+			SiteURL:      "https://kittenbot.io",
+			Timezone:     "UTC",
+			FirstDay:     "20230720",
+			StorageClass: "STANDARD",
+			CDN:          "none",
+			PromptsParam: "/kittenbot/prompts",
+			Subreddit:    "kittenbot",
+			Variants:     1,
+
+			SiteTitle:       "KittenBot",
+			SiteDescription: "Daily AI Generated Kittens",
+
+			ModerationAttempts: 3,
+			DedupDays:          30,
+			DedupDistance:      4,
+			VerifyDistance:     4,
+		},
+		Now: now,
+		Fetcher: Fetcher{
+			"/kittenbot/prompts/0": "cyberrealistic_1_3|cute kitten",
+		},
+		Generator:   NewGenerator("cyberrealistic_1_3", "icbinp"),
+		Invalidator: &Invalidator{},
+		Poster:      &Poster{},
+		Notifier:    &Notifier{},
+	}
+	f.Store = NewStore(f.clock())
+	return f
+}
+
+func (f *Injector) clock() clock.Clock {
+	return func() time.Time {
+		return f.Now
+	}
+}
+
+// Build wires the fakes together with the real implementations of everything else, the way
+// inject.Setup does for a deployment.
+func (f *Injector) Build() *do.Injector {
+	i := do.New()
+
+	do.ProvideValue[*config.Config](i, f.Config)
+	do.Provide[*day.Calendar](i, func(*do.Injector) (*day.Calendar, error) {
+		loc, err := time.LoadLocation(f.Config.Timezone)
+		return day.NewCalendar(loc), err
+	})
+	do.ProvideNamed[[]string](i, "prompts", func(i *do.Injector) ([]string, error) {
+		fetcher, err := do.Invoke[param.Fetcher](i)
+		if err != nil {
+			return nil, err
+		}
+		params, err := fetcher.FetchAll(context.Background(), f.Config.PromptsParam)
+		return param.Values(params), err
+	})
+	do.ProvideNamedValue[string](i, "site_url", f.Config.SiteURL)
+	do.ProvideNamedValue[string](i, "site_title", f.Config.SiteTitle)
+	do.ProvideNamedValue[string](i, "site_description", f.Config.SiteDescription)
+	do.ProvideNamedValue[string](i, "theme_dir", f.Config.ThemeDir)
+	do.ProvideNamedValue[string](i, "first_day", f.Config.FirstDay)
+	do.ProvideNamedValue[int](i, "variants", f.Config.Variants)
+	do.ProvideNamedValue[float64](i, "budget", f.Config.Budget)
+	do.ProvideNamedValue[float64](i, "balance_warning", f.Config.BalanceWarning)
+	do.ProvideNamedValue[int](i, "moderation_attempts", f.Config.ModerationAttempts)
+	do.ProvideNamedValue[int](i, "dedup_days", f.Config.DedupDays)
+	do.ProvideNamedValue[int](i, "dedup_distance", f.Config.DedupDistance)
+	do.ProvideNamedValue[int](i, "verify_distance", f.Config.VerifyDistance)
+	do.ProvideNamedValue[bool](i, "staged", f.Config.Staged)
+	do.ProvideNamedValue[bool](i, "notify_success", f.Config.NotifySuccess)
+
+	do.Provide[moderate.Moderator](i, moderate.NewNoopModerator)
+	do.Provide[*prompt.Randomizer](i, prompt.NewRandomizer)
+	do.Provide[*index.Index](i, index.NewIndex)
+	do.Provide[*page.Templator](i, page.NewTemplator)
+	do.Provide[*feed.Generator](i, feed.NewGenerator)
+	do.Provide[*sitemap.Generator](i, sitemap.NewGenerator)
+	do.Provide[*api.Generator](i, api.NewGenerator)
+	do.Provide[*handler.Handler](i, handler.NewHandler)
+	do.Provide[*verify.Verifier](i, verify.NewVerifier)
+
+	f.Override(i)
+	return i
+}
+
+// Override replaces every external service wired into i, and the clock, with the fakes. It
+// leaves the rest of the wiring alone, so it runs an injector built by inject.Setup without
+// AWS, Dezgo or Reddit. Costs are still metered through the ledger.
+func (f *Injector) Override(i *do.Injector) {
+	do.OverrideValue[clock.Clock](i, f.clock())
+	do.OverrideValue[param.Fetcher](i, f.Fetcher)
+	do.Override[image.Generator](i, ledger.Meter(func(*do.Injector) (image.Generator, error) {
+		return f.Generator, nil
+	}))
+	do.OverrideValue[store.Uploader](i, f.Store)
+	do.OverrideValue[store.Copier](i, f.Store)
+	do.OverrideValue[store.Deleter](i, f.Store)
+	do.OverrideValue[store.Reader](i, f.Store)
+	do.OverrideValue[store.Invalidator](i, f.Invalidator)
+	do.Override[lock.Locker](i, func(*do.Injector) (lock.Locker, error) {
+		return lock.NewStoreLocker(f.Store, f.Store, f.Store, f.clock(), 0)
+	})
+	do.OverrideValue[post.Poster](i, f.Poster)
+	do.OverrideValue[notify.Notifier](i, f.Notifier)
+}
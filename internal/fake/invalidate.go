@@ -0,0 +1,23 @@
+package fake
+
+import (
+	"context"
+	"sync"
+)
+
+type Invalidator struct {
+	mu      sync.Mutex
+	Err     error
+	Batches [][]string
+}
+
+func (i *Invalidator) Invalidate(_ context.Context, paths []string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.Err != nil {
+		return i.Err
+	}
+	i.Batches = append(i.Batches, append([]string(nil), paths...))
+	return nil
+}
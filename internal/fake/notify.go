@@ -0,0 +1,25 @@
+package fake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dmorgan81/kittenbot/internal/notify"
+)
+
+type Notifier struct {
+	mu     sync.Mutex
+	Err    error
+	Events []notify.Event
+}
+
+func (n *Notifier) Notify(_ context.Context, e notify.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.Err != nil {
+		return n.Err
+	}
+	n.Events = append(n.Events, e)
+	return nil
+}
@@ -0,0 +1,36 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dmorgan81/kittenbot/internal/param"
+	"github.com/samber/lo"
+)
+
+// Fetcher serves parameters from a map. FetchAll returns every entry under path/.
+type Fetcher map[string]string
+
+func (f Fetcher) Fetch(_ context.Context, path string) (string, error) {
+	v, ok := f[path]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", path, param.ErrNotFound)
+	}
+	return v, nil
+}
+
+func (f Fetcher) FetchAll(_ context.Context, path string) ([]param.Param, error) {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	names := lo.Filter(lo.Keys(f), func(name string, _ int) bool {
+		return strings.HasPrefix(name, prefix)
+	})
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s: %w", path, param.ErrNotFound)
+	}
+	sort.Strings(names)
+	return lo.Map(names, func(name string, _ int) param.Param {
+		return param.Param{Name: name, Value: f[name]}
+	}), nil
+}
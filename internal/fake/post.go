@@ -0,0 +1,25 @@
+package fake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dmorgan81/kittenbot/internal/post"
+)
+
+type Poster struct {
+	mu    sync.Mutex
+	Err   error
+	Posts []post.Params
+}
+
+func (p *Poster) Post(_ context.Context, params post.Params) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Err != nil {
+		return p.Err
+	}
+	p.Posts = append(p.Posts, params)
+	return nil
+}
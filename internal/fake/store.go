@@ -0,0 +1,158 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/lo"
+)
+
+// StoredObject is an object held by Store along with everything it was uploaded with.
+type StoredObject struct {
+	store.UploadParams
+	LastModified time.Time
+}
+
+func (o StoredObject) object() store.Object {
+	return store.Object{Name: o.Name, Metadata: o.Metadata, LastModified: o.LastModified}
+}
+
+// Store keeps objects in memory and implements every store interface. Errors set in Fail
+// are returned by the matching operation, keyed by "<operation>:<name>" where operation is
+// one of upload, copy, delete, stat or read.
+type Store struct {
+	mu      sync.Mutex
+	now     clock.Clock
+	objects map[string]StoredObject
+	Fail    map[string]error
+	Ops     []string
+}
+
+func NewStore(now clock.Clock) *Store {
+	return &Store{now: now, objects: make(map[string]StoredObject), Fail: make(map[string]error)}
+}
+
+func (s *Store) op(op, name string) error {
+	s.Ops = append(s.Ops, op+":"+name)
+	return s.Fail[op+":"+name]
+}
+
+func (s *Store) Upload(_ context.Context, params store.UploadParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.op("upload", params.Name); err != nil {
+		return err
+	}
+	s.objects[params.Name] = StoredObject{params, s.now()}
+	return nil
+}
+
+func (s *Store) Copy(_ context.Context, params store.CopyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.op("copy", params.Name); err != nil {
+		return err
+	}
+	src, ok := s.objects[params.Source]
+	if !ok {
+		return fmt.Errorf("copy %s: %w", params.Source, store.ErrNotFound)
+	}
+	src.Name = params.Name
+	src.LastModified = s.now()
+	if params.CacheControl != "" {
+		src.CacheControl = params.CacheControl
+	}
+	s.objects[params.Name] = src
+	return nil
+}
+
+func (s *Store) Delete(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.op("delete", name); err != nil {
+		return err
+	}
+	delete(s.objects, name)
+	return nil
+}
+
+func (s *Store) Stat(_ context.Context, name string) (store.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.Fail["stat:"+name]; err != nil {
+		return store.Object{}, err
+	}
+	obj, ok := s.objects[name]
+	if !ok {
+		return store.Object{}, store.ErrNotFound
+	}
+	return obj.object(), nil
+}
+
+func (s *Store) Read(_ context.Context, name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.Fail["read:"+name]; err != nil {
+		return nil, err
+	}
+	obj, ok := s.objects[name]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return obj.Data, nil
+}
+
+func (s *Store) List(_ context.Context, params store.ListParams) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := lo.Filter(lo.Keys(s.objects), func(name string, _ int) bool {
+		rest, ok := strings.CutPrefix(name, params.Prefix)
+		if params.Delimiter != "" && strings.Contains(rest, params.Delimiter) {
+			return false
+		}
+		return ok && name > params.StartAfter
+	})
+	sort.Strings(names)
+	return names, nil
+}
+
+// Get returns an object directly, bypassing Fail.
+func (s *Store) Get(name string) (StoredObject, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[name]
+	return obj, ok
+}
+
+// Put stores an object directly, bypassing Fail and Ops.
+func (s *Store) Put(params store.UploadParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[params.Name] = StoredObject{params, s.now()}
+}
+
+// Objects returns every stored object ordered by name.
+func (s *Store) Objects() []StoredObject {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objs := lo.Values(s.objects)
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].Name < objs[j].Name
+	})
+	return objs
+}
@@ -3,13 +3,13 @@ package feed
 import (
 	"context"
 	"fmt"
-	"regexp"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/day"
 	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/store"
 	"github.com/gorilla/feeds"
 	"github.com/samber/do"
 	"github.com/samber/lo"
@@ -19,80 +19,74 @@ import (
 const last30Days = time.Hour * 24 * 30
 
 type Generator struct {
-	client *s3.Client
-	bucket string
+	reader      store.Reader
+	now         clock.Clock
+	calendar    *day.Calendar
+	site        string
+	title       string
+	description string
 }
 
-func NewS3Generator(i *do.Injector) (*Generator, error) {
-	client := do.MustInvoke[*s3.Client](i)
-	bucket := do.MustInvokeNamed[string](i, "bucket")
-	return &Generator{client, bucket}, nil
+func NewGenerator(i *do.Injector) (*Generator, error) {
+	reader, err := do.Invoke[store.Reader](i)
+	if err != nil {
+		return nil, err
+	}
+	now := do.MustInvoke[clock.Clock](i)
+	calendar := do.MustInvoke[*day.Calendar](i)
+	site := strings.TrimSuffix(do.MustInvokeNamed[string](i, "site_url"), "/")
+	title := do.MustInvokeNamed[string](i, "site_title")
+	description := do.MustInvokeNamed[string](i, "site_description")
+	return &Generator{reader, now, calendar, site, title, description}, nil
 }
 
 func (g *Generator) Generate(ctx context.Context) ([]byte, error) {
 	log := log.FromContextOrDiscard(ctx).WithGroup("feed")
 	log.Info("generating rss feed")
 
+	now := g.calendar.In(g.now())
 	feed := feeds.Feed{
-		Title:       "KittenBot",
-		Description: "Daily AI Generated Kittens",
-		Link:        &feeds.Link{Href: "https://kittenbot.io"},
-		Updated:     time.Now(),
+		Title:       g.title,
+		Description: g.description,
+		Link:        &feeds.Link{Href: g.site},
+		Updated:     now,
 	}
 
-	start := time.Now().Add(-last30Days).Format("20060102")
-	pager := s3.NewListObjectsV2Paginator(g.client, &s3.ListObjectsV2Input{
-		Bucket:     &g.bucket,
-		StartAfter: aws.String(start + ".png"),
+	start := g.calendar.Key(now.Add(-last30Days))
+	names, err := g.reader.List(ctx, store.ListParams{StartAfter: start + ".png", Delimiter: "/"})
+	if err != nil {
+		return nil, err
+	}
+	names = lo.Filter(names, func(name string, _ int) bool {
+		_, _, ok := day.ParseName(name, ".png")
+		return ok
 	})
 
-	items := make(chan *feeds.Item)
-	defer close(items)
-
-	go func(items <-chan *feeds.Item) {
-		for i := range items {
-			feed.Add(i)
-		}
-	}(items)
-
+	items := make([]*feeds.Item, len(names))
 	group, ctx := errgroup.WithContext(ctx)
-	for pager.HasMorePages() {
-		page, err := pager.NextPage(ctx)
-		if err != nil {
-			return nil, err
-		}
-
-		pattern := regexp.MustCompile(`^\d{8}\.png$`)
-		objs := lo.Filter(page.Contents, func(o s3types.Object, _ int) bool {
-			return pattern.MatchString(*o.Key)
+	for idx, name := range names {
+		idx, name := idx, name
+		group.Go(func() error {
+			obj, err := g.reader.Stat(ctx, name)
+			if err != nil {
+				return err
+			}
+
+			meta := obj.Metadata
+			items[idx] = &feeds.Item{
+				Title:   fmt.Sprintf("%s:%s:%s", meta["prompt"], meta["model"], meta["seed"]),
+				Link:    &feeds.Link{Href: g.site + "/" + name},
+				Updated: g.calendar.In(obj.LastModified),
+			}
+			return nil
 		})
-
-		for _, obj := range objs {
-			obj := obj
-			group.Go(func() error {
-				out, err := g.client.HeadObject(ctx, &s3.HeadObjectInput{
-					Bucket: &g.bucket,
-					Key:    obj.Key,
-				})
-				if err != nil {
-					return err
-				}
-
-				meta := out.Metadata
-				items <- &feeds.Item{
-					Title:   fmt.Sprintf("%s:%s:%s", meta["prompt"], meta["model"], meta["seed"]),
-					Link:    &feeds.Link{Href: fmt.Sprintf("https://kittenbot.io/%s.png", meta["date"])},
-					Updated: *out.LastModified,
-				}
-				return nil
-			})
-		}
 	}
 
 	if err := group.Wait(); err != nil {
 		return nil, err
 	}
 
+	feed.Items = items
 	feed.Sort(func(a, b *feeds.Item) bool {
 		return a.Updated.Before(b.Updated)
 	})
@@ -0,0 +1,43 @@
+package feed_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/feed"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+)
+
+// TestGenerateSite links the feed and its items to the configured site.
+func TestGenerateSite(t *testing.T) {
+	f := fake.NewInjector(time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC))
+	f.Config.SiteURL = "https://kittens.example/"
+	f.Config.SiteTitle = "Kittens"
+	f.Config.SiteDescription = "A kitten a day"
+	f.Store.Put(store.UploadParams{Name: "20231201.png", Metadata: map[string]string{"prompt": "cute kitten", "model": "icbinp", "seed": "42"}})
+	f.Store.Put(store.UploadParams{Name: "latest.png"})
+
+	data, err := do.MustInvoke[*feed.Generator](f.Build()).Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rss := string(data)
+	for _, want := range []string{
+		"<title>Kittens</title>",
+		"<description>A kitten a day</description>",
+		"<link>https://kittens.example</link>",
+		"<title>cute kitten:icbinp:42</title>",
+		"<link>https://kittens.example/20231201.png</link>",
+	} {
+		if !strings.Contains(rss, want) {
+			t.Errorf("feed is missing %s:\n%s", want, rss)
+		}
+	}
+	if strings.Contains(rss, "kittenbot.io") || strings.Contains(rss, "latest.png") {
+		t.Errorf("feed links outside the configured site's days:\n%s", rss)
+	}
+}
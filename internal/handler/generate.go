@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dmorgan81/kittenbot/internal/image"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/moderate"
+	"github.com/samber/lo"
+)
+
+// generated is an image the moderator allowed. cost adds up what every attempt cost, nil
+// when the generator does not say.
+type generated struct {
+	data     []byte
+	seed     string
+	verdict  moderate.Verdict
+	attempts int
+	cost     *float64
+}
+
+// metadata records the cost and the moderation verdict, leaving out the verdict when
+// moderation is disabled.
+func (g generated) metadata() map[string]string {
+	metadata := make(map[string]string)
+	if g.cost != nil {
+		metadata["cost"] = strconv.FormatFloat(*g.cost, 'f', -1, 64)
+	}
+	if g.verdict.Moderator == "" {
+		return metadata
+	}
+	metadata["moderation"] = "allowed"
+	metadata["moderated_by"] = g.verdict.Moderator
+	metadata["moderation_attempts"] = strconv.Itoa(g.attempts)
+	if g.verdict.Score != "" {
+		metadata["moderation_score"] = g.verdict.Score
+	}
+	return metadata
+}
+
+// generate asks for images until the moderator allows one, moving on to the next seed after
+// each rejection, and gives up after the configured number of attempts.
+func (h *Handler) generate(ctx context.Context, generator image.Generator, moderator moderate.Moderator, date string, params image.Params) (generated, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("moderation")
+
+	var verdict moderate.Verdict
+	var cost *float64
+	for attempt := 1; attempt <= h.attempts; attempt++ {
+		img, err := generator.Generate(ctx, params)
+		if err != nil {
+			return generated{}, err
+		}
+		if img.Cost != nil {
+			cost = lo.ToPtr(lo.FromPtr(cost) + *img.Cost)
+		}
+		if verdict, err = moderator.Moderate(ctx, date, img.Data); err != nil {
+			return generated{}, err
+		}
+		if verdict.Allowed {
+			return generated{img.Data, img.Seed, verdict, attempt, cost}, nil
+		}
+		log.Warn("image rejected, regenerating", "seed", img.Seed, "reason", verdict.Reason, "attempt", attempt)
+		params.Seed = nextSeed(img.Seed)
+	}
+	return generated{}, fmt.Errorf("%w %d times: %s", moderate.ErrRejected, h.attempts, verdict.Reason)
+}
+
+// nextSeed returns the seed after seed, wrapping around at the largest 32-bit seed.
+func nextSeed(seed string) string {
+	n, _ := strconv.ParseUint(seed, 10, 32)
+	return strconv.FormatUint(uint64(uint32(n+1)), 10)
+}
@@ -1,15 +1,29 @@
 package handler
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"image/png"
+	"maps"
+	"path"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/dmorgan81/kittenbot/internal/api"
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/day"
 	"github.com/dmorgan81/kittenbot/internal/feed"
 	"github.com/dmorgan81/kittenbot/internal/image"
+	"github.com/dmorgan81/kittenbot/internal/index"
 	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/moderate"
 	"github.com/dmorgan81/kittenbot/internal/page"
+	"github.com/dmorgan81/kittenbot/internal/phash"
 	"github.com/dmorgan81/kittenbot/internal/post"
 	"github.com/dmorgan81/kittenbot/internal/prompt"
+	"github.com/dmorgan81/kittenbot/internal/sitemap"
 	"github.com/dmorgan81/kittenbot/internal/store"
 	"github.com/samber/do"
 	"github.com/samber/lo"
@@ -20,80 +34,246 @@ type Phase string
 const (
 	PhaseImage      Phase = "image"
 	PhaseFeed       Phase = "feed"
+	PhaseSitemap    Phase = "sitemap"
 	PhaseInvalidate Phase = "invalidate"
 	PhasePost       Phase = "post"
 )
 
-var AllPhases = []Phase{PhaseImage, PhaseFeed, PhaseInvalidate, PhasePost}
+var AllPhases = []Phase{PhaseImage, PhaseFeed, PhaseSitemap, PhaseInvalidate, PhasePost}
 
-type Input struct {
-	Date   string  `json:"date,omitempty"`
-	Model  string  `json:"model,omitempty"`
-	Prompt string  `json:"prompt,omitempty"`
-	Seed   string  `json:"seed,omitempty"`
-	Phases []Phase `json:"phases,omitempty"`
+// PhaseError is returned when a phase fails, naming the phase.
+type PhaseError struct {
+	Phase Phase
+	Err   error
+}
+
+func (e *PhaseError) Error() string {
+	return fmt.Sprintf("%s phase: %v", e.Phase, e.Err)
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+var latestPaths = []string{"/latest.png", "/latest.html", "/api/latest.json"}
+
+// feedPaths are the objects the feed phase rewrites.
+var feedPaths = []string{"/feed.xml", "/archive.html", "/404.html", "/api/index.json"}
+
+// sitemapPaths are the objects the sitemap phase always rewrites.
+var sitemapPaths = []string{"/sitemap.xml", "/robots.txt"}
+
+// Variant is one of several kittens generated for the same day. Empty fields fall back to
+// the input's.
+type Variant struct {
+	Model  string `json:"model,omitempty"`
+	Prompt string `json:"prompt,omitempty"`
+	Seed   string `json:"seed,omitempty"`
 }
 
-func (i Input) toImageParams() image.Params {
+func (v Variant) toImageParams() image.Params {
 	return image.Params{
-		Model:  i.Model,
-		Prompt: i.Prompt,
-		Seed:   i.Seed,
+		Model:  v.Model,
+		Prompt: v.Prompt,
+		Seed:   v.Seed,
 	}
 }
 
-func (i Input) toPageParams() page.Params {
-	return page.Params{
-		Image:  i.Date + ".png",
-		Model:  i.Model,
-		Prompt: i.Prompt,
-		Seed:   i.Seed,
+// Input describes a run. Without Variants the day has a single image named YYYYMMDD.png;
+// with them each is numbered YYYYMMDD-1.png and so on, and Pick, counting from 1, chooses
+// the one promoted to latest.*.
+type Input struct {
+	Date     string    `json:"date,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Prompt   string    `json:"prompt,omitempty"`
+	Seed     string    `json:"seed,omitempty"`
+	Variants []Variant `json:"variants,omitempty"`
+	Pick     int       `json:"pick,omitempty"`
+	Phases   []Phase   `json:"phases,omitempty"`
+}
+
+// images returns every image of the day, a single one when there are no variants.
+func (i Input) images() []Variant {
+	if len(i.Variants) == 0 {
+		return []Variant{{Model: i.Model, Prompt: i.Prompt, Seed: i.Seed}}
 	}
+	return i.Variants
 }
 
-func (i Input) toMetadata() map[string]string {
-	return map[string]string{
+// imageName returns the object name of the nth image, counting from 0.
+func (i Input) imageName(n int) string {
+	return day.Name(i.Date, lo.Ternary(len(i.Variants) > 0, n+1, 0), ".png")
+}
+
+// pick returns the index of the pick of the day in images.
+func (i Input) pick() int {
+	return max(i.Pick, 1) - 1
+}
+
+// objectNames returns the names of the day's images followed by its page and JSON.
+func (i Input) objectNames() []string {
+	return append(lo.Times(len(i.images()), i.imageName), i.Date+".html", i.Date+".json")
+}
+
+// cacheControl returns how long the day's object name may be cached. Pages are rewritten
+// when a neighbouring day is published, everything else never changes.
+func cacheControl(name string) string {
+	return lo.Ternary(path.Ext(name) == ".html", store.CacheShort, store.CacheImmutable)
+}
+
+func uploadPaths(uploads []store.UploadParams) []string {
+	return lo.Map(uploads, func(u store.UploadParams, _ int) string {
+		return "/" + u.Name
+	})
+}
+
+func (i Input) datedPaths() []string {
+	return lo.Map(i.objectNames(), func(name string, _ int) string {
+		return "/" + name
+	})
+}
+
+// promotions returns the copies that make the day latest.*, if it is the latest day.
+func (i Input) promotions(latest bool) []store.CopyParams {
+	if !latest {
+		return nil
+	}
+	return []store.CopyParams{
+		{Source: i.imageName(i.pick()), Name: "latest.png", CacheControl: store.CacheShort},
+		{Source: i.Date + ".html", Name: "latest.html", CacheControl: store.CacheShort},
+		{Source: i.Date + ".json", Name: "api/latest.json", CacheControl: store.CacheShort},
+	}
+}
+
+func (i *Input) setSeed(n int, seed string) {
+	if len(i.Variants) == 0 {
+		i.Seed = seed
+	} else {
+		i.Variants[n].Seed = seed
+	}
+}
+
+// toPageParams describes the day's page, given the metadata of each image.
+func (i Input) toPageParams(date time.Time, metadata []map[string]string) page.Params {
+	pick := i.images()[i.pick()]
+	params := page.Params{
+		Date:     date,
+		Image:    i.imageName(i.pick()),
+		Model:    pick.Model,
+		Prompt:   pick.Prompt,
+		Seed:     pick.Seed,
+		Metadata: metadata[i.pick()],
+	}
+	params.Width, _ = strconv.Atoi(params.Metadata["width"])
+	params.Height, _ = strconv.Atoi(params.Metadata["height"])
+	if len(i.Variants) > 0 {
+		params.Variants = lo.Map(i.Variants, func(v Variant, n int) page.Variant {
+			return page.Variant{
+				Image:    i.imageName(n),
+				Model:    v.Model,
+				Prompt:   v.Prompt,
+				Seed:     v.Seed,
+				Metadata: metadata[n],
+			}
+		})
+	}
+	return params
+}
+
+// toMetadata returns the metadata of the nth image.
+func (i Input) toMetadata(n int) map[string]string {
+	v := i.images()[n]
+	metadata := map[string]string{
 		"date":   i.Date,
-		"model":  i.Model,
-		"prompt": i.Prompt,
-		"seed":   i.Seed,
+		"model":  v.Model,
+		"prompt": v.Prompt,
+		"seed":   v.Seed,
+	}
+	if len(i.Variants) > 0 {
+		metadata["variant"] = strconv.Itoa(n + 1)
 	}
+	return metadata
+}
+
+// toPageMetadata returns the metadata of the day's page, which describes the pick of the day.
+func (i Input) toPageMetadata() map[string]string {
+	metadata := i.toMetadata(i.pick())
+	delete(metadata, "variant")
+	if len(i.Variants) > 0 {
+		metadata["variants"] = strconv.Itoa(len(i.Variants))
+		metadata["pick"] = strconv.Itoa(i.Pick)
+	}
+	return metadata
 }
 
 func (i Input) toPostParams() post.Params {
+	pick := i.images()[i.pick()]
 	return post.Params{
 		Date:   i.Date,
-		Model:  i.Model,
-		Prompt: i.Prompt,
-		Seed:   i.Seed,
+		Image:  i.imageName(i.pick()),
+		Model:  pick.Model,
+		Prompt: pick.Prompt,
+		Seed:   pick.Seed,
 	}
 }
 
 type Output Input
 
+// Handler resolves each phase's dependencies only when that phase runs, so a
+// misconfigured dependency fails the phases that need it rather than every invocation.
 type Handler struct {
-	randomizer     *prompt.Randomizer
-	imageGenerator image.Generator
-	uploader       store.Uploader
-	invalidator    store.Invalidator
-	templator      *page.Templator
-	feedGenerator  *feed.Generator
-	poster         post.Poster
+	injector *do.Injector
+	now      clock.Clock
+	calendar *day.Calendar
+	firstDay string
+	variants int
+	attempts int
+	staged   bool
+	success  bool
+	claims   sync.Mutex
 }
 
 func NewHandler(i *do.Injector) (*Handler, error) {
+	firstDay, err := do.InvokeNamed[string](i, "first_day")
+	if err != nil {
+		return nil, err
+	}
+	variants, err := do.InvokeNamed[int](i, "variants")
+	if err != nil {
+		return nil, err
+	}
+	attempts, err := do.InvokeNamed[int](i, "moderation_attempts")
+	if err != nil {
+		return nil, err
+	}
+	staged, err := do.InvokeNamed[bool](i, "staged")
+	if err != nil {
+		return nil, err
+	}
+	success, err := do.InvokeNamed[bool](i, "notify_success")
+	if err != nil {
+		return nil, err
+	}
 	return &Handler{
-		randomizer:     do.MustInvoke[*prompt.Randomizer](i),
-		imageGenerator: do.MustInvoke[image.Generator](i),
-		uploader:       do.MustInvoke[store.Uploader](i),
-		invalidator:    do.MustInvoke[store.Invalidator](i),
-		templator:      do.MustInvoke[*page.Templator](i),
-		feedGenerator:  do.MustInvoke[*feed.Generator](i),
-		poster:         do.MustInvoke[post.Poster](i),
+		injector: i,
+		now:      do.MustInvoke[clock.Clock](i),
+		calendar: do.MustInvoke[*day.Calendar](i),
+		firstDay: firstDay,
+		variants: variants,
+		attempts: attempts,
+		staged:   staged,
+		success:  success,
 	}, nil
 }
 
+// Handle runs the input's phases and notifies how the run ended.
 func (h *Handler) Handle(ctx context.Context, input Input) (Output, error) {
+	output, err := h.handle(ctx, input)
+	h.notify(ctx, "run", input, err)
+	return output, err
+}
+
+func (h *Handler) handle(ctx context.Context, input Input) (Output, error) {
 	log := log.FromContextOrDiscard(ctx).WithGroup("Handler").With("input", input)
 	log.Info("handling lambda invocation")
 
@@ -102,99 +282,296 @@ func (h *Handler) Handle(ctx context.Context, input Input) (Output, error) {
 	}
 	log.Info("", "phases", input.Phases)
 
-	if input.Model == "" || input.Prompt == "" {
-		model, prompt, err := h.randomizer.Randomize(ctx)
+	var models []string
+	if lo.Contains(input.Phases, PhaseImage) {
+		imageGenerator, err := do.Invoke[image.Generator](h.injector)
+		if err != nil {
+			return Output{}, err
+		}
+		if lister, ok := imageGenerator.(image.ModelLister); ok {
+			models = lister.Models()
+		}
+	}
+	if len(input.Variants) == 0 && h.variants > 1 {
+		input.Variants = make([]Variant, h.variants)
+	}
+	if err := input.Validate(h.firstDay, h.calendar.Key(h.now()), models); err != nil {
+		return Output{}, err
+	}
+
+	unset := lo.SomeBy(input.images(), func(v Variant) bool {
+		return (v.Model == "" && input.Model == "") || (v.Prompt == "" && input.Prompt == "")
+	})
+	if unset && lo.Some(input.Phases, []Phase{PhaseImage, PhasePost}) {
+		randomizer, err := do.Invoke[*prompt.Randomizer](h.injector)
+		if err != nil {
+			return Output{}, err
+		}
+		model, prompt, err := randomizer.Randomize(ctx)
 		if err != nil {
 			return Output{}, err
 		}
 		input.Model = lo.Ternary(input.Model != "", input.Model, model)
 		input.Prompt = lo.Ternary(input.Prompt != "", input.Prompt, prompt)
 	}
+	for n := range input.Variants {
+		v := &input.Variants[n]
+		v.Model = lo.Ternary(v.Model != "", v.Model, input.Model)
+		v.Prompt = lo.Ternary(v.Prompt != "", v.Prompt, input.Prompt)
+		v.Seed = lo.Ternary(v.Seed != "", v.Seed, input.Seed)
+	}
+	if len(input.Variants) > 0 && input.Pick == 0 {
+		input.Pick = 1
+	}
 
-	latest := false
-	if input.Date == "" {
-		input.Date = time.Now().UTC().Format("20060102")
-		latest = true
+	latest := input.Date == ""
+	if latest {
+		input.Date = h.calendar.Key(h.now())
 	}
+	return h.run(ctx, input, latest)
+}
 
+// run executes the phases of a validated input whose date has been decided. In staged mode
+// it stops after the image phase, leaving the rest to Approve.
+func (h *Handler) run(ctx context.Context, input Input, latest bool) (Output, error) {
+	var paths store.Paths
 	if lo.Contains(input.Phases, PhaseImage) {
-		img, seed, err := h.imageGenerator.Generate(ctx, input.toImageParams())
+		uploads, err := h.render(ctx, &input)
 		if err != nil {
-			return Output{}, err
+			return Output{}, &PhaseError{PhaseImage, err}
+		}
+
+		if h.staged {
+			if err := h.stage(ctx, input, latest, uploads); err != nil {
+				return Output{}, &PhaseError{PhaseImage, err}
+			}
+			return Output(input), nil
 		}
-		input.Seed = seed
 
-		html, err := h.templator.Template(ctx, input.toPageParams())
+		objects, err := h.objectStore()
 		if err != nil {
-			return Output{}, err
+			return Output{}, &PhaseError{PhaseImage, err}
+		}
+		relinked, err := h.relink(ctx, input)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseImage, err}
+		}
+		if err := objects.publish(ctx, append(uploads, relinked...), nil, input.promotions(latest)); err != nil {
+			return Output{}, &PhaseError{PhaseImage, err}
+		}
+
+		paths.Add(input.datedPaths()...)
+		paths.Add(uploadPaths(relinked)...)
+		if latest {
+			paths.Add(latestPaths...)
+		}
+	}
+	return h.finish(ctx, input, latest, paths)
+}
+
+// render generates the day's images, page and JSON, ready to upload.
+func (h *Handler) render(ctx context.Context, input *Input) ([]store.UploadParams, error) {
+	imageGenerator, err := do.Invoke[image.Generator](h.injector)
+	if err != nil {
+		return nil, err
+	}
+	templator, err := do.Invoke[*page.Templator](h.injector)
+	if err != nil {
+		return nil, err
+	}
+	moderator, err := do.Invoke[moderate.Moderator](h.injector)
+	if err != nil {
+		return nil, err
+	}
+	days, err := do.Invoke[*index.Index](h.injector)
+	if err != nil {
+		return nil, err
+	}
+	apiGenerator, err := do.Invoke[*api.Generator](h.injector)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]generated, len(input.images()))
+	for n, v := range input.images() {
+		if images[n], err = h.generate(ctx, imageGenerator, moderator, input.Date, v.toImageParams()); err != nil {
+			return nil, err
+		}
+		input.setSeed(n, images[n].seed)
+	}
+
+	uploads := make([]store.UploadParams, 0, len(images)+2)
+	for n, img := range images {
+		metadata := input.toMetadata(n)
+		maps.Copy(metadata, img.metadata())
+		if hash, err := phash.DHash(img.data); err != nil {
+			log.FromContextOrDiscard(ctx).Warn("not recording perceptual hash", "name", input.imageName(n), "error", err)
+		} else {
+			metadata["dhash"] = phash.Format(hash)
+		}
+		if cfg, err := png.DecodeConfig(bytes.NewReader(img.data)); err != nil {
+			log.FromContextOrDiscard(ctx).Warn("not recording dimensions", "name", input.imageName(n), "error", err)
+		} else {
+			metadata["width"] = strconv.Itoa(cfg.Width)
+			metadata["height"] = strconv.Itoa(cfg.Height)
+		}
+		uploads = append(uploads, store.UploadParams{
+			Name:         input.imageName(n),
+			Data:         img.data,
+			ContentType:  "image/png",
+			CacheControl: store.CacheImmutable,
+			Metadata:     metadata,
+		})
+	}
+
+	date, err := h.calendar.Parse(input.Date)
+	if err != nil {
+		return nil, err
+	}
+	params := input.toPageParams(date, lo.Map(uploads, func(u store.UploadParams, _ int) map[string]string {
+		return u.Metadata
+	}))
+	if params.Previous, params.Next, err = days.Neighbours(ctx, input.Date); err != nil {
+		return nil, err
+	}
+	html, err := templator.Day(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := apiGenerator.Day(input.Date, input.Pick, uploads)
+	if err != nil {
+		return nil, err
+	}
+
+	uploads = append(uploads, store.UploadParams{
+		Name:         input.Date + ".html",
+		Data:         html,
+		ContentType:  "text/html",
+		CacheControl: store.CacheShort,
+		Metadata:     input.toPageMetadata(),
+	}, store.UploadParams{
+		Name:         input.Date + ".json",
+		Data:         doc,
+		ContentType:  "application/json",
+		CacheControl: store.CacheImmutable,
+		Metadata:     input.toPageMetadata(),
+	})
+	return uploads, nil
+}
+
+// finish runs the phases that follow publishing the day's images.
+func (h *Handler) finish(ctx context.Context, input Input, latest bool, paths store.Paths) (Output, error) {
+	if lo.Contains(input.Phases, PhaseFeed) {
+		feedGenerator, err := do.Invoke[*feed.Generator](h.injector)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseFeed, err}
+		}
+		templator, err := do.Invoke[*page.Templator](h.injector)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseFeed, err}
+		}
+		days, err := do.Invoke[*index.Index](h.injector)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseFeed, err}
+		}
+		apiGenerator, err := do.Invoke[*api.Generator](h.injector)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseFeed, err}
+		}
+		uploader, err := do.Invoke[store.Uploader](h.injector)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseFeed, err}
+		}
+
+		feed, err := feedGenerator.Generate(ctx)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseFeed, err}
+		}
+		all, err := days.Days(ctx)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseFeed, err}
+		}
+		archive, err := templator.Archive(ctx, all)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseFeed, err}
+		}
+		notFound, err := templator.NotFound(ctx)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseFeed, err}
+		}
+		apiIndex, err := apiGenerator.Index(ctx, all)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseFeed, err}
 		}
 
-		metadata := input.toMetadata()
 		uploads := []store.UploadParams{
-			{
-				Name:        input.Date + ".png",
-				Data:        img,
-				ContentType: "image/png",
-				Metadata:    metadata,
-			},
-			{
-				Name:        input.Date + ".html",
-				Data:        html,
-				ContentType: "text/html",
-				Metadata:    metadata,
-			},
+			{Name: "feed.xml", Data: feed, ContentType: "text/xml", CacheControl: store.CacheShort},
+			{Name: "archive.html", Data: archive, ContentType: "text/html", CacheControl: store.CacheShort},
+			{Name: "404.html", Data: notFound, ContentType: "text/html", CacheControl: store.CacheShort},
+			{Name: "api/index.json", Data: apiIndex, ContentType: "application/json", CacheControl: store.CacheShort},
 		}
-		if latest {
-			uploads = append(uploads,
-				store.UploadParams{
-					Name:        "latest.png",
-					Data:        img,
-					ContentType: "image/png",
-					Metadata:    metadata,
-				},
-				store.UploadParams{
-					Name:        "latest.html",
-					Data:        html,
-					ContentType: "text/html",
-					Metadata:    metadata,
-				},
-			)
-		}
-		for _, u := range uploads {
-			if err := h.uploader.Upload(ctx, u); err != nil {
-				return Output{}, err
+		for _, upload := range uploads {
+			if err := uploader.Upload(ctx, upload); err != nil {
+				return Output{}, &PhaseError{PhaseFeed, err}
 			}
 		}
+		paths.Add(feedPaths...)
 	}
 
-	if lo.Contains(input.Phases, PhaseFeed) {
-		feed, err := h.feedGenerator.Generate(ctx)
+	if lo.Contains(input.Phases, PhaseSitemap) {
+		sitemapGenerator, err := do.Invoke[*sitemap.Generator](h.injector)
 		if err != nil {
-			return Output{}, err
+			return Output{}, &PhaseError{PhaseSitemap, err}
+		}
+		uploader, err := do.Invoke[store.Uploader](h.injector)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseSitemap, err}
 		}
 
-		if err := h.uploader.Upload(ctx, store.UploadParams{
-			Name:        "feed.xml",
-			Data:        feed,
-			ContentType: "text/xml",
-		}); err != nil {
-			return Output{}, err
+		files, err := sitemapGenerator.Generate(ctx)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseSitemap, err}
+		}
+		for _, f := range files {
+			if err := uploader.Upload(ctx, store.UploadParams{
+				Name:         f.Name,
+				Data:         f.Data,
+				ContentType:  f.ContentType,
+				CacheControl: store.CacheShort,
+			}); err != nil {
+				return Output{}, &PhaseError{PhaseSitemap, err}
+			}
+			paths.Add("/" + f.Name)
 		}
 	}
 
 	if lo.Contains(input.Phases, PhaseInvalidate) {
-		paths := []string{"/" + input.Date + ".png", "/" + input.Date + ".html", "/feed.xml"}
-		if latest {
-			paths = append(paths, "/latest.png", "/latest.html")
+		invalidator, err := do.Invoke[store.Invalidator](h.injector)
+		if err != nil {
+			return Output{}, &PhaseError{PhaseInvalidate, err}
 		}
-		if err := h.invalidator.Invalidate(ctx, paths); err != nil {
-			return Output{}, err
+
+		// Invalidating on its own refreshes everything a full run would have touched.
+		if paths.Len() == 0 {
+			paths.Add(input.datedPaths()...)
+			paths.Add(feedPaths...)
+			paths.Add(sitemapPaths...)
+			if latest {
+				paths.Add(latestPaths...)
+			}
+		}
+		if err := invalidator.Invalidate(ctx, paths.Items()); err != nil {
+			return Output{}, &PhaseError{PhaseInvalidate, err}
 		}
 	}
 
 	if latest && lo.Contains(input.Phases, PhasePost) {
-		if err := h.poster.Post(ctx, input.toPostParams()); err != nil {
-			return Output{}, err
+		poster, err := do.Invoke[post.Poster](h.injector)
+		if err != nil {
+			return Output{}, &PhaseError{PhasePost, err}
+		}
+		if err := poster.Post(ctx, input.toPostParams()); err != nil {
+			return Output{}, &PhaseError{PhasePost, err}
 		}
 	}
 
@@ -0,0 +1,532 @@
+package handler_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/handler"
+	"github.com/dmorgan81/kittenbot/internal/image"
+	"github.com/dmorgan81/kittenbot/internal/ledger"
+	"github.com/dmorgan81/kittenbot/internal/moderate"
+	"github.com/dmorgan81/kittenbot/internal/notify"
+	"github.com/dmorgan81/kittenbot/internal/post"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+var now = time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC)
+
+var errBoom = errors.New("boom")
+
+type object struct {
+	Name         string            `json:"name"`
+	ContentType  string            `json:"contentType"`
+	CacheControl string            `json:"cacheControl,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	SHA256       string            `json:"sha256"`
+}
+
+type result struct {
+	Output        *handler.Output `json:"output,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	Generated     []image.Params  `json:"generated"`
+	Objects       []object        `json:"objects"`
+	Invalidations [][]string      `json:"invalidations"`
+	Posts         []post.Params   `json:"posts"`
+	Notifications []notify.Event  `json:"notifications,omitempty"`
+}
+
+// seedPreviousDay stores yesterday's kitten and promotes it to latest.
+func seedPreviousDay(f *fake.Injector) {
+	meta := map[string]string{"date": "20231130", "model": "icbinp", "prompt": "old kitten", "seed": "42"}
+	for _, name := range []string{"20231130.png", "latest.png"} {
+		f.Store.Put(store.UploadParams{Name: name, Data: []byte("old png"), ContentType: "image/png", Metadata: meta})
+	}
+	for _, name := range []string{"20231130.html", "latest.html"} {
+		f.Store.Put(store.UploadParams{Name: name, Data: []byte("old html"), ContentType: "text/html", Metadata: meta})
+	}
+}
+
+// seedPreviousVariantDay stores yesterday's two variants and promotes the second to latest.
+func seedPreviousVariantDay(f *fake.Injector) {
+	for n, name := range []string{"20231130-1.png", "20231130-2.png"} {
+		meta := map[string]string{"date": "20231130", "model": "icbinp", "prompt": "old kitten", "seed": strconv.Itoa(n), "variant": strconv.Itoa(n + 1)}
+		f.Store.Put(store.UploadParams{Name: name, Data: []byte(name), ContentType: "image/png", Metadata: meta})
+	}
+	f.Store.Put(store.UploadParams{Name: "latest.png", Data: []byte("20231130-2.png"), ContentType: "image/png",
+		Metadata: map[string]string{"date": "20231130", "model": "icbinp", "prompt": "old kitten", "seed": "1", "variant": "2"}})
+	meta := map[string]string{"date": "20231130", "model": "icbinp", "prompt": "old kitten", "seed": "1", "variants": "2", "pick": "2"}
+	for _, name := range []string{"20231130.html", "latest.html"} {
+		f.Store.Put(store.UploadParams{Name: name, Data: []byte("old html"), ContentType: "text/html", Metadata: meta})
+	}
+}
+
+// blocklist moderates with a blocklist of the fake images generated from each key.
+func blocklist(keys ...string) func(*do.Injector) {
+	return func(i *do.Injector) {
+		hashes := lo.Map(keys, func(key string, _ int) string {
+			return moderate.Hash(lo.Must(fake.Image(key)))
+		})
+		do.ProvideNamedValue[[]string](i, "moderation_blocklist", hashes)
+		do.Override[moderate.Moderator](i, moderate.NewBlocklistModerator)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name  string
+		input handler.Input
+		setup func(*fake.Injector)
+		wire  func(*do.Injector)
+	}{
+		{
+			name:  "all phases latest",
+			input: handler.Input{},
+			setup: seedPreviousDay,
+		},
+		{
+			name: "variants with pick",
+			input: handler.Input{
+				Variants: []handler.Variant{{Seed: "1"}, {Prompt: "sleepy kitten", Seed: "2"}, {Seed: "3"}},
+				Pick:     2,
+			},
+			setup: seedPreviousDay,
+		},
+		{
+			name:  "variants from config",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseImage, handler.PhaseInvalidate}},
+			setup: func(f *fake.Injector) {
+				f.Config.Variants = 2
+			},
+		},
+		{
+			name: "invalid variants",
+			input: handler.Input{
+				Variants: []handler.Variant{{Model: "unknown_model"}, {Seed: "x"}},
+				Pick:     3,
+			},
+		},
+		{
+			name:  "variant promotion fails",
+			input: handler.Input{Variants: []handler.Variant{{Seed: "1"}, {Seed: "2"}}},
+			setup: func(f *fake.Injector) {
+				seedPreviousVariantDay(f)
+				f.Store.Fail["copy:latest.html"] = errBoom
+			},
+		},
+		{
+			name:  "moderation regenerates",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseImage}},
+			wire:  blocklist("cute kitten1234"),
+		},
+		{
+			name:  "moderation rejects every attempt",
+			input: handler.Input{},
+			setup: seedPreviousDay,
+			wire:  blocklist("cute kitten1234", "cute kitten1235", "cute kitten1236"),
+		},
+		{
+			name:  "dedup regenerates",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseImage}},
+			setup: func(f *fake.Injector) {
+				// Stored before hashes were recorded, so dedup has to hash it itself.
+				f.Store.Put(store.UploadParams{Name: "20231130.png", Data: lo.Must(fake.Image("cute kitten1234")), ContentType: "image/png"})
+			},
+			wire: func(i *do.Injector) {
+				do.Override[moderate.Moderator](i, moderate.NewDedupModerator)
+			},
+		},
+		{
+			name:  "cost recorded in ledger",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseImage}},
+			setup: func(f *fake.Injector) {
+				f.Generator.Cost = 0.0102
+				f.Generator.Balance = 1.5
+				f.Config.BalanceWarning = 2
+			},
+			wire: blocklist("cute kitten1234"),
+		},
+		{
+			name:  "budget exceeded",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseImage}},
+			setup: func(f *fake.Injector) {
+				f.Generator.Cost = 0.01
+				f.Config.Budget = 0.05
+				for n, cost := range []float64{0.03, 0.015} {
+					e := ledger.Entry{Time: f.Now.Add(-time.Duration(2-n) * time.Minute), Seed: strconv.Itoa(n), Cost: cost}
+					f.Store.Put(store.UploadParams{
+						Name:        ledger.EntryName("2023-12", e),
+						Data:        lo.Must(json.Marshal(e)),
+						ContentType: "application/json",
+					})
+				}
+			},
+		},
+		{
+			name:  "site timezone behind utc",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseImage}},
+			setup: func(f *fake.Injector) {
+				f.Config.Timezone = "America/New_York"
+			},
+		},
+		{
+			name:  "all phases backfill",
+			input: handler.Input{Date: "20231115", Model: "icbinp", Prompt: "backfilled kitten", Seed: "7"},
+			setup: seedPreviousDay,
+		},
+		{
+			name:  "feed only",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseFeed}},
+			setup: seedPreviousDay,
+		},
+		{
+			name:  "invalidate only",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseInvalidate}},
+		},
+		{
+			name:  "image and invalidate batch",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseImage, handler.PhaseFeed, handler.PhaseInvalidate}},
+			setup: seedPreviousDay,
+		},
+		{
+			name: "invalid input",
+			input: handler.Input{
+				Date:   "2023-12-01",
+				Model:  "unknown_model",
+				Seed:   "-1",
+				Phases: []handler.Phase{"image", "paint"},
+			},
+		},
+		{
+			name:  "future date",
+			input: handler.Input{Date: "20231202"},
+		},
+		{
+			name:  "generator fails",
+			input: handler.Input{},
+			setup: func(f *fake.Injector) {
+				seedPreviousDay(f)
+				f.Generator.Err = errBoom
+			},
+		},
+		{
+			name:  "dated upload fails",
+			input: handler.Input{},
+			setup: func(f *fake.Injector) {
+				seedPreviousDay(f)
+				f.Store.Fail["upload:20231201.html"] = errBoom
+			},
+		},
+		{
+			name:  "rerun upload fails",
+			input: handler.Input{},
+			setup: func(f *fake.Injector) {
+				seedPreviousDay(f)
+				meta := map[string]string{"date": "20231201", "model": "icbinp", "prompt": "first kitten", "seed": "7"}
+				for _, name := range []string{"20231201.png", "20231201.html", "20231201.json", "latest.png", "latest.html"} {
+					f.Store.Put(store.UploadParams{Name: name, Data: []byte("first " + name), Metadata: meta, CacheControl: store.CacheImmutable})
+				}
+				f.Store.Fail["upload:20231201.json"] = errBoom
+			},
+		},
+		{
+			name:  "rerun promotion fails",
+			input: handler.Input{},
+			setup: func(f *fake.Injector) {
+				seedPreviousDay(f)
+				meta := map[string]string{"date": "20231201", "model": "icbinp", "prompt": "first kitten", "seed": "7"}
+				for _, name := range []string{"20231201.png", "20231201.html", "20231201.json", "latest.png", "latest.html"} {
+					f.Store.Put(store.UploadParams{Name: name, Data: []byte("first " + name), Metadata: meta, CacheControl: store.CacheImmutable})
+				}
+				f.Store.Fail["copy:latest.html"] = errBoom
+			},
+		},
+		{
+			name:  "promotion fails",
+			input: handler.Input{},
+			setup: func(f *fake.Injector) {
+				seedPreviousDay(f)
+				f.Store.Fail["copy:latest.html"] = errBoom
+			},
+		},
+		{
+			name:  "invalidation fails",
+			input: handler.Input{},
+			setup: func(f *fake.Injector) {
+				seedPreviousDay(f)
+				f.Invalidator.Err = errBoom
+			},
+		},
+		{
+			name:  "success notified",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseFeed}},
+			setup: func(f *fake.Injector) {
+				f.Config.NotifySuccess = true
+			},
+		},
+		{
+			name:  "notifier fails",
+			input: handler.Input{},
+			setup: func(f *fake.Injector) {
+				seedPreviousDay(f)
+				f.Generator.Err = errBoom
+				f.Notifier.Err = errors.New("webhook unreachable")
+			},
+		},
+		{
+			name:  "poster unavailable for feed",
+			input: handler.Input{Phases: []handler.Phase{handler.PhaseFeed}},
+			wire: func(i *do.Injector) {
+				do.Override[post.Poster](i, func(*do.Injector) (post.Poster, error) {
+					return nil, errors.New("reddit credentials missing")
+				})
+			},
+		},
+		{
+			name:  "poster unavailable for post",
+			input: handler.Input{Phases: []handler.Phase{handler.PhasePost}},
+			wire: func(i *do.Injector) {
+				do.Override[post.Poster](i, func(*do.Injector) (post.Poster, error) {
+					return nil, errors.New("reddit credentials missing")
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			f := fake.NewInjector(now)
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			i := f.Build()
+			if tt.wire != nil {
+				tt.wire(i)
+			}
+
+			h := do.MustInvoke[*handler.Handler](i)
+			output, err := h.Handle(context.Background(), tt.input)
+
+			res := snapshot(f, output, err)
+			golden(t, res)
+		})
+	}
+}
+
+// snapshot records the outcome of a call and everything it did to the fakes.
+func snapshot(f *fake.Injector, output handler.Output, err error) result {
+	res := result{
+		Generated:     f.Generator.Calls,
+		Invalidations: f.Invalidator.Batches,
+		Posts:         f.Poster.Posts,
+		Notifications: f.Notifier.Events,
+	}
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Output = &output
+	}
+	for _, obj := range f.Store.Objects() {
+		sum := sha256.Sum256(obj.Data)
+		res.Objects = append(res.Objects, object{
+			Name:         obj.Name,
+			ContentType:  obj.ContentType,
+			CacheControl: obj.CacheControl,
+			Metadata:     obj.Metadata,
+			SHA256:       hex.EncodeToString(sum[:]),
+		})
+	}
+	return res
+}
+
+// TestStaged stages today's kitten, rejects it, then approves the regenerated one. Each
+// step's golden file holds the state of the fakes after it.
+func TestStaged(t *testing.T) {
+	ctx := context.Background()
+	f := fake.NewInjector(now)
+	f.Config.Staged = true
+	f.Config.NotifySuccess = true
+	seedPreviousDay(f)
+	h := do.MustInvoke[*handler.Handler](f.Build())
+
+	t.Run("stage", func(t *testing.T) {
+		output, err := h.Handle(ctx, handler.Input{})
+		golden(t, snapshot(f, output, err))
+	})
+	t.Run("reject", func(t *testing.T) {
+		output, err := h.Reject(ctx, "20231201")
+		golden(t, snapshot(f, output, err))
+	})
+	t.Run("approve", func(t *testing.T) {
+		output, err := h.Approve(ctx, "20231201")
+		golden(t, snapshot(f, output, err))
+	})
+	t.Run("approve again", func(t *testing.T) {
+		if _, err := h.Approve(ctx, "20231201"); !errors.Is(err, handler.ErrNotPending) {
+			t.Errorf("got %v, want ErrNotPending", err)
+		}
+	})
+}
+
+// TestRelink backfills a day between two published ones and checks both of their pages
+// now link to it.
+func TestRelink(t *testing.T) {
+	ctx := context.Background()
+	f := fake.NewInjector(now)
+	h := do.MustInvoke[*handler.Handler](f.Build())
+	for _, date := range []string{"20231128", "20231130", "20231129"} {
+		if _, err := h.Handle(ctx, handler.Input{Date: date, Phases: []handler.Phase{handler.PhaseImage}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"20231128.html": `<a href="https://kittenbot.io/20231129.html" rel="next">`,
+		"20231130.html": `<a href="https://kittenbot.io/20231129.html" rel="prev">`,
+	} {
+		obj, _ := f.Store.Get(name)
+		if !strings.Contains(string(obj.Data), want) {
+			t.Errorf("%s does not contain %s", name, want)
+		}
+		if obj.CacheControl != store.CacheShort {
+			t.Errorf("%s has Cache-Control %q, want %q", name, obj.CacheControl, store.CacheShort)
+		}
+	}
+}
+
+// TestApproveOnce approves the same staged run from several callers at once. Only one of
+// them publishes and posts; the others find nothing pending.
+func TestApproveOnce(t *testing.T) {
+	ctx := context.Background()
+	f := fake.NewInjector(now)
+	f.Config.Staged = true
+	h := do.MustInvoke[*handler.Handler](f.Build())
+	if _, err := h.Handle(ctx, handler.Input{}); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 4)
+	for n := 0; n < cap(errs); n++ {
+		go func() {
+			_, err := h.Approve(ctx, "20231201")
+			errs <- err
+		}()
+	}
+	var approved int
+	for n := 0; n < cap(errs); n++ {
+		switch err := <-errs; {
+		case err == nil:
+			approved++
+		case !errors.Is(err, handler.ErrNotPending):
+			t.Errorf("got %v, want ErrNotPending", err)
+		}
+	}
+	if approved != 1 || len(f.Poster.Posts) != 1 {
+		t.Errorf("got %d approvals and %d posts, want 1 of each", approved, len(f.Poster.Posts))
+	}
+}
+
+// TestApproveFailureStaysPending fails to publish an approved run, which leaves it pending
+// so the approval can be retried.
+func TestApproveFailureStaysPending(t *testing.T) {
+	ctx := context.Background()
+	f := fake.NewInjector(now)
+	f.Config.Staged = true
+	h := do.MustInvoke[*handler.Handler](f.Build())
+	if _, err := h.Handle(ctx, handler.Input{}); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Store.Fail["copy:latest.png"] = errBoom
+	if _, err := h.Approve(ctx, "20231201"); !errors.Is(err, errBoom) {
+		t.Fatalf("got %v, want errBoom", err)
+	}
+	if _, err := h.Staged(ctx, "20231201"); err != nil {
+		t.Fatalf("run is no longer pending: %v", err)
+	}
+
+	delete(f.Store.Fail, "copy:latest.png")
+	if _, err := h.Approve(ctx, "20231201"); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Poster.Posts) != 1 {
+		t.Errorf("got %d posts, want 1", len(f.Poster.Posts))
+	}
+}
+
+func golden(t *testing.T, v any) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", filepath.FromSlash(t.Name())+".golden.json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s does not match, run go test -update and review the diff\ngot:\n%s", path, got)
+	}
+}
+
+// TestApproveAfterNewerDay approves a day staged as the latest after a later day has been
+// published. The later day keeps latest.* and the approved day is not posted.
+func TestApproveAfterNewerDay(t *testing.T) {
+	ctx := context.Background()
+	f := fake.NewInjector(now)
+	f.Config.Staged = true
+	h := do.MustInvoke[*handler.Handler](f.Build())
+	if _, err := h.Handle(ctx, handler.Input{}); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := map[string]string{"date": "20231202", "model": "icbinp", "prompt": "newer kitten", "seed": "42"}
+	for _, name := range []string{"20231202.png", "latest.png"} {
+		f.Store.Put(store.UploadParams{Name: name, Data: []byte("newer png"), ContentType: "image/png", Metadata: meta})
+	}
+	for _, name := range []string{"20231202.html", "latest.html"} {
+		f.Store.Put(store.UploadParams{Name: name, Data: []byte("newer html"), ContentType: "text/html", Metadata: meta})
+	}
+
+	if _, err := h.Approve(ctx, "20231201"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.Store.Get("20231201.html"); !ok {
+		t.Errorf("approved day was not published")
+	}
+	if obj, _ := f.Store.Get("latest.html"); string(obj.Data) != "newer html" {
+		t.Errorf("latest.html was replaced by the approved day")
+	}
+	if len(f.Poster.Posts) != 0 {
+		t.Errorf("got %d posts, want none", len(f.Poster.Posts))
+	}
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/notify"
+	"github.com/samber/do"
+)
+
+// notify tells the configured notifier how an action ended. Successes are only sent when
+// notify_success is set. A notifier that fails is logged rather than failing the run. The
+// notification is sent even when the run ended because ctx was cancelled, but gives up
+// after notify.Timeout.
+func (h *Handler) notify(ctx context.Context, action string, input Input, err error) {
+	if err == nil && !h.success {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notify.Timeout)
+	defer cancel()
+	log := log.FromContextOrDiscard(ctx).WithGroup("notify")
+
+	var phase Phase
+	var perr *PhaseError
+	if errors.As(err, &perr) {
+		phase = perr.Phase
+	}
+	event := notify.NewEvent(action, string(phase), input, err, h.now())
+
+	notifier, nerr := do.Invoke[notify.Notifier](h.injector)
+	if nerr != nil {
+		log.Error("resolving notifier", "error", nerr)
+		return
+	}
+	if nerr := notifier.Notify(ctx, event); nerr != nil {
+		log.Error("sending notification", "event", event.Subject(), "error", nerr)
+	}
+}
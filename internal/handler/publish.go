@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strconv"
+	"sync"
+
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+)
+
+// objectStore is everything publishing needs from the store.
+type objectStore struct {
+	store.Uploader
+	store.Copier
+	store.Deleter
+	store.Reader
+}
+
+func (h *Handler) objectStore() (objectStore, error) {
+	var o objectStore
+	var err error
+	if o.Uploader, err = do.Invoke[store.Uploader](h.injector); err != nil {
+		return o, err
+	}
+	if o.Copier, err = do.Invoke[store.Copier](h.injector); err != nil {
+		return o, err
+	}
+	if o.Deleter, err = do.Invoke[store.Deleter](h.injector); err != nil {
+		return o, err
+	}
+	o.Reader, err = do.Invoke[store.Reader](h.injector)
+	return o, err
+}
+
+// backupPrefix is where publish keeps copies of the objects it is about to overwrite until
+// it knows it will not have to put them back.
+const backupPrefix = "rollback/"
+
+// publish writes the dated objects concurrently, by uploading them or copying them from
+// elsewhere in the store, and only once every one of them has succeeded promotes them to
+// their latest.* names with server-side copies. Any failure rolls back what this call
+// already changed so latest.* never references a missing object: objects that did not
+// exist before are deleted and ones that were overwritten are restored from a backup
+// taken before writing.
+func (o objectStore) publish(ctx context.Context, uploads []store.UploadParams, copies, promotions []store.CopyParams) error {
+	log := log.FromContextOrDiscard(ctx).WithGroup("publish")
+
+	names := make([]string, 0, len(uploads)+len(copies))
+	for _, u := range uploads {
+		names = append(names, u.Name)
+	}
+	for _, c := range copies {
+		names = append(names, c.Name)
+	}
+	backups, err := o.backup(ctx, names)
+	defer func() {
+		if err := o.discardBackups(ctx, backups); err != nil {
+			log.Error("deleting backups", "error", err)
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu       sync.Mutex
+		uploaded []string
+	)
+	written := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		uploaded = append(uploaded, name)
+	}
+	group, gctx := errgroup.WithContext(ctx)
+	for _, u := range uploads {
+		u := u
+		group.Go(func() error {
+			if err := o.Upload(gctx, u); err != nil {
+				return err
+			}
+			written(u.Name)
+			return nil
+		})
+	}
+	for _, c := range copies {
+		c := c
+		group.Go(func() error {
+			if err := o.Copy(gctx, c); err != nil {
+				return err
+			}
+			written(c.Name)
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		log.Error("upload failed, rolling back", "error", err, "uploaded", uploaded)
+		return errors.Join(err, o.rollback(ctx, uploaded, backups, nil))
+	}
+
+	previous := make(map[string]string, len(promotions))
+	for _, p := range promotions {
+		obj, err := o.Stat(ctx, p.Name)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+		case err != nil:
+			return errors.Join(err, o.rollback(ctx, uploaded, backups, nil))
+		case obj.Metadata["date"] != "":
+			variant, _ := strconv.Atoi(obj.Metadata["variant"])
+			previous[p.Name] = day.Name(obj.Metadata["date"], variant, path.Ext(p.Name))
+		}
+	}
+
+	var promoted []store.CopyParams
+	for _, p := range promotions {
+		if err := o.Copy(ctx, p); err != nil {
+			log.Error("promotion failed, rolling back", "error", err, "promoted", promoted)
+			return errors.Join(err, o.rollback(ctx, uploaded, backups, restorations(promoted, previous)))
+		}
+		promoted = append(promoted, p)
+	}
+	return nil
+}
+
+// restorations returns the copies needed to put promoted objects back to what they were
+// before. Objects that did not exist before are restored with an empty Source.
+func restorations(promoted []store.CopyParams, previous map[string]string) []store.CopyParams {
+	restores := make([]store.CopyParams, 0, len(promoted))
+	for _, p := range promoted {
+		restores = append(restores, store.CopyParams{
+			Source:       previous[p.Name],
+			Name:         p.Name,
+			CacheControl: p.CacheControl,
+		})
+	}
+	return restores
+}
+
+// backup copies each of names that already exists under backupPrefix, returning the names
+// it backed up even when it fails part way.
+func (o objectStore) backup(ctx context.Context, names []string) ([]string, error) {
+	var (
+		mu     sync.Mutex
+		backed []string
+	)
+	group, gctx := errgroup.WithContext(ctx)
+	for _, name := range names {
+		name := name
+		group.Go(func() error {
+			_, err := o.Stat(gctx, name)
+			if errors.Is(err, store.ErrNotFound) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := o.Copy(gctx, store.CopyParams{Source: name, Name: backupPrefix + name}); err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			backed = append(backed, name)
+			return nil
+		})
+	}
+	err := group.Wait()
+	return backed, err
+}
+
+func (o objectStore) discardBackups(ctx context.Context, backups []string) error {
+	ctx = context.WithoutCancel(ctx)
+
+	errs := make([]error, 0, len(backups))
+	for _, name := range backups {
+		errs = append(errs, o.Delete(ctx, backupPrefix+name))
+	}
+	return errors.Join(errs...)
+}
+
+// rollback restores the uploaded objects that were backed up and deletes the rest, then
+// undoes the promotions with restores, which may copy from the restored objects.
+func (o objectStore) rollback(ctx context.Context, uploaded, backups []string, restores []store.CopyParams) error {
+	ctx = context.WithoutCancel(ctx)
+
+	var errs []error
+	for _, name := range uploaded {
+		if lo.Contains(backups, name) {
+			errs = append(errs, o.Copy(ctx, store.CopyParams{Source: backupPrefix + name, Name: name}))
+		} else {
+			errs = append(errs, o.Delete(ctx, name))
+		}
+	}
+	for _, r := range restores {
+		if r.Source == "" {
+			errs = append(errs, o.Delete(ctx, r.Name))
+		} else {
+			errs = append(errs, o.Copy(ctx, r))
+		}
+	}
+	return errors.Join(errs...)
+}
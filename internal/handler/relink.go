@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/dmorgan81/kittenbot/internal/index"
+	"github.com/dmorgan81/kittenbot/internal/page"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+// Page renders the page of a published day from the stored metadata of its images, linking
+// the days either side of it as they are now.
+func (h *Handler) Page(ctx context.Context, date string) ([]byte, error) {
+	templator, err := do.Invoke[*page.Templator](h.injector)
+	if err != nil {
+		return nil, err
+	}
+	days, err := do.Invoke[*index.Index](h.injector)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := do.Invoke[store.Reader](h.injector)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := days.Day(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	params, err := storedParams(ctx, reader, d)
+	if err != nil {
+		return nil, err
+	}
+	if params.Previous, params.Next, err = days.Neighbours(ctx, date); err != nil {
+		return nil, err
+	}
+	return templator.Day(ctx, params)
+}
+
+// relink re-renders the pages of the published days either side of input so that their
+// previous and next links lead to it, returning them ready to upload.
+func (h *Handler) relink(ctx context.Context, input Input) ([]store.UploadParams, error) {
+	templator, err := do.Invoke[*page.Templator](h.injector)
+	if err != nil {
+		return nil, err
+	}
+	days, err := do.Invoke[*index.Index](h.injector)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := do.Invoke[store.Reader](h.injector)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := h.calendar.Parse(input.Date)
+	if err != nil {
+		return nil, err
+	}
+	current := &index.Day{Key: input.Date, Date: date, Metadata: input.toPageMetadata(), LastModified: h.now()}
+
+	keys, err := days.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	before := lo.Filter(keys, func(k string, _ int) bool { return k < input.Date })
+	after := lo.Filter(keys, func(k string, _ int) bool { return k > input.Date })
+
+	var uploads []store.UploadParams
+	if len(before) > 0 {
+		var previous *index.Day
+		if len(before) > 1 {
+			if previous, err = lookup(ctx, days, before[len(before)-2]); err != nil {
+				return nil, err
+			}
+		}
+		u, err := rerender(ctx, templator, reader, days, before[len(before)-1], previous, current)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+	if len(after) > 0 {
+		var next *index.Day
+		if len(after) > 1 {
+			if next, err = lookup(ctx, days, after[1]); err != nil {
+				return nil, err
+			}
+		}
+		u, err := rerender(ctx, templator, reader, days, after[0], current, next)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, nil
+}
+
+func lookup(ctx context.Context, days *index.Index, key string) (*index.Day, error) {
+	d, err := days.Day(ctx, key)
+	return &d, err
+}
+
+// rerender renders the page of the published day key with the given neighbours.
+func rerender(ctx context.Context, templator *page.Templator, reader store.Reader, days *index.Index, key string, previous, next *index.Day) (store.UploadParams, error) {
+	d, err := days.Day(ctx, key)
+	if err != nil {
+		return store.UploadParams{}, err
+	}
+	params, err := storedParams(ctx, reader, d)
+	if err != nil {
+		return store.UploadParams{}, err
+	}
+	params.Previous, params.Next = previous, next
+	html, err := templator.Day(ctx, params)
+	return store.UploadParams{
+		Name:         d.Page(),
+		Data:         html,
+		ContentType:  "text/html",
+		CacheControl: store.CacheShort,
+		Metadata:     d.Metadata,
+	}, err
+}
+
+// storedParams describes a published day's page from the stored metadata of its images.
+func storedParams(ctx context.Context, reader store.Reader, d index.Day) (page.Params, error) {
+	params := page.Params{
+		Date:     d.Date,
+		Image:    d.Image(),
+		Model:    d.Metadata["model"],
+		Prompt:   d.Metadata["prompt"],
+		Seed:     d.Metadata["seed"],
+		Metadata: d.Metadata,
+	}
+	_, variants := d.Metadata["variants"]
+	for _, name := range d.Images() {
+		obj, err := reader.Stat(ctx, name)
+		if err != nil {
+			return page.Params{}, err
+		}
+		if variants {
+			params.Variants = append(params.Variants, page.Variant{
+				Image:    name,
+				Model:    obj.Metadata["model"],
+				Prompt:   obj.Metadata["prompt"],
+				Seed:     obj.Metadata["seed"],
+				Metadata: obj.Metadata,
+			})
+		}
+		if name == params.Image {
+			params.Metadata = obj.Metadata
+		}
+	}
+	params.Width, _ = strconv.Atoi(params.Metadata["width"])
+	params.Height, _ = strconv.Atoi(params.Metadata["height"])
+	return params, nil
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/index"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+// pendingPrefix is where staged runs keep their objects until they are approved.
+const pendingPrefix = "pending/"
+
+// manifestExt names a staged run's manifest apart from the day's own JSON beside it.
+const manifestExt = ".manifest.json"
+
+// ErrNotPending is returned when there is no staged run for a date.
+var ErrNotPending = errors.New("nothing is pending approval")
+
+// manifest is what a staged run needs to resume once it is approved or rejected.
+type manifest struct {
+	Input  Input `json:"input"`
+	Latest bool  `json:"latest"`
+}
+
+// PendingManifest returns the name of the manifest a staged run for date leaves in the store.
+func PendingManifest(date string) string {
+	return pendingPrefix + date + manifestExt
+}
+
+// stage uploads a run's objects under pending/ along with its manifest.
+func (h *Handler) stage(ctx context.Context, input Input, latest bool, uploads []store.UploadParams) error {
+	log.FromContextOrDiscard(ctx).WithGroup("stage").Info("staging for approval", "date", input.Date)
+
+	objects, err := h.objectStore()
+	if err != nil {
+		return err
+	}
+	upload, err := manifest{input, latest}.upload()
+	if err != nil {
+		return err
+	}
+
+	staged := lo.Map(uploads, func(u store.UploadParams, _ int) store.UploadParams {
+		u.Name = pendingPrefix + u.Name
+		u.CacheControl = store.CacheNone
+		return u
+	})
+	return objects.publish(ctx, append(staged, upload), nil, nil)
+}
+
+func (m manifest) upload() (store.UploadParams, error) {
+	data, err := json.Marshal(m)
+	return store.UploadParams{
+		Name:         PendingManifest(m.Input.Date),
+		Data:         data,
+		ContentType:  "application/json",
+		CacheControl: store.CacheNone,
+	}, err
+}
+
+func (h *Handler) manifest(ctx context.Context, reader store.Reader, date string) (manifest, error) {
+	var m manifest
+	data, err := reader.Read(ctx, PendingManifest(date))
+	if errors.Is(err, store.ErrNotFound) {
+		return m, fmt.Errorf("%s: %w", date, ErrNotPending)
+	} else if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// claim takes the staged run for date by deleting its manifest before acting on it, so an
+// approval or rejection racing this one, or repeating it, gets ErrNotPending rather than
+// publishing and posting the day twice. Claims are serialised within the process; across
+// processes only the moment between reading and deleting the manifest is left to race.
+func (h *Handler) claim(ctx context.Context, objects objectStore, date string) (manifest, error) {
+	h.claims.Lock()
+	defer h.claims.Unlock()
+
+	m, err := h.manifest(ctx, objects, date)
+	if err != nil {
+		return m, err
+	}
+	return m, objects.Delete(ctx, PendingManifest(date))
+}
+
+// unclaim puts back the manifest of a staged run that could not be acted on, so it can be
+// approved or rejected again.
+func (h *Handler) unclaim(ctx context.Context, objects objectStore, m manifest) error {
+	upload, err := m.upload()
+	if err != nil {
+		return err
+	}
+	return objects.Upload(context.WithoutCancel(ctx), upload)
+}
+
+// discard deletes a claimed run's staged objects.
+func (h *Handler) discard(ctx context.Context, objects objectStore, input Input) error {
+	var errs []error
+	for _, name := range input.objectNames() {
+		errs = append(errs, objects.Delete(ctx, pendingPrefix+name))
+	}
+	return errors.Join(errs...)
+}
+
+// Pending returns every staged run waiting for approval, oldest first.
+func (h *Handler) Pending(ctx context.Context) ([]Output, error) {
+	objects, err := h.objectStore()
+	if err != nil {
+		return nil, err
+	}
+	names, err := objects.List(ctx, store.ListParams{Prefix: pendingPrefix, Delimiter: "/"})
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Output
+	for _, name := range names {
+		date, ok := day.FromName(strings.TrimPrefix(name, pendingPrefix), manifestExt)
+		if !ok {
+			continue
+		}
+		m, err := h.manifest(ctx, objects, date)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, Output(m.Input))
+	}
+	return pending, nil
+}
+
+// Staged returns the staged run for a date, or ErrNotPending.
+func (h *Handler) Staged(ctx context.Context, date string) (Output, error) {
+	objects, err := h.objectStore()
+	if err != nil {
+		return Output{}, err
+	}
+	m, err := h.manifest(ctx, objects, date)
+	return Output(m.Input), err
+}
+
+// Approve publishes a staged run: its objects are copied from pending/ to their dated names,
+// latest.* is promoted if the run was for the latest day and no later day has been published
+// since, and the phases after the image phase run as they would have without staging.
+func (h *Handler) Approve(ctx context.Context, date string) (Output, error) {
+	output, err := h.approve(ctx, date)
+	h.notify(ctx, "approve", Input{Date: date}, err)
+	return output, err
+}
+
+func (h *Handler) approve(ctx context.Context, date string) (Output, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("Handler").With("date", date)
+	log.Info("approving staged run")
+
+	objects, err := h.objectStore()
+	if err != nil {
+		return Output{}, err
+	}
+	m, err := h.claim(ctx, objects, date)
+	if err != nil {
+		return Output{}, err
+	}
+	input := m.Input
+	latest := m.Latest
+	if latest {
+		if latest, err = h.newest(ctx, input.Date); err != nil {
+			return Output{}, &PhaseError{PhaseImage, errors.Join(err, h.unclaim(ctx, objects, m))}
+		}
+		if !latest {
+			log.Warn("a later day has been published since staging, not promoting to latest")
+		}
+	}
+
+	copies := lo.Map(input.objectNames(), func(name string, _ int) store.CopyParams {
+		return store.CopyParams{Source: pendingPrefix + name, Name: name, CacheControl: cacheControl(name)}
+	})
+	relinked, err := h.relink(ctx, input)
+	if err != nil {
+		return Output{}, &PhaseError{PhaseImage, errors.Join(err, h.unclaim(ctx, objects, m))}
+	}
+	if err := objects.publish(ctx, relinked, copies, input.promotions(latest)); err != nil {
+		return Output{}, &PhaseError{PhaseImage, errors.Join(err, h.unclaim(ctx, objects, m))}
+	}
+	if err := h.discard(ctx, objects, input); err != nil {
+		log.Error("discarding approved objects", "error", err)
+	}
+
+	var paths store.Paths
+	paths.Add(input.datedPaths()...)
+	paths.Add(uploadPaths(relinked)...)
+	if latest {
+		paths.Add(latestPaths...)
+	}
+	return h.finish(ctx, input, latest, paths)
+}
+
+// newest reports whether no day after date has been published.
+func (h *Handler) newest(ctx context.Context, date string) (bool, error) {
+	days, err := do.Invoke[*index.Index](h.injector)
+	if err != nil {
+		return false, err
+	}
+	keys, err := days.Keys(ctx)
+	if err != nil {
+		return false, err
+	}
+	return !lo.ContainsBy(keys, func(k string) bool { return k > date }), nil
+}
+
+// Reject discards a staged run and generates it again from the next seeds.
+func (h *Handler) Reject(ctx context.Context, date string) (Output, error) {
+	output, err := h.reject(ctx, date)
+	h.notify(ctx, "reject", Input{Date: date}, err)
+	return output, err
+}
+
+func (h *Handler) reject(ctx context.Context, date string) (Output, error) {
+	log.FromContextOrDiscard(ctx).WithGroup("Handler").Info("rejecting staged run", "date", date)
+
+	objects, err := h.objectStore()
+	if err != nil {
+		return Output{}, err
+	}
+	m, err := h.claim(ctx, objects, date)
+	if err != nil {
+		return Output{}, err
+	}
+	if err := h.discard(ctx, objects, m.Input); err != nil {
+		return Output{}, errors.Join(err, h.unclaim(ctx, objects, m))
+	}
+
+	input := m.Input
+	for n, v := range input.images() {
+		input.setSeed(n, nextSeed(v.Seed))
+	}
+	return h.run(ctx, input, m.Latest)
+}
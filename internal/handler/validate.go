@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/samber/lo"
+)
+
+const (
+	maxPromptLength = 1000
+	maxVariants     = 10
+)
+
+// ValidationError aggregates every problem found with an Input so callers can fix them all at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid input: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the input against the site's first and today's day keys and the models
+// known to the provider. A nil or empty models list skips the model check.
+func (i Input) Validate(first, today string, models []string) error {
+	var problems []string
+
+	if i.Date != "" {
+		if !day.IsKey(i.Date) {
+			problems = append(problems, fmt.Sprintf("date %q must be formatted as YYYYMMDD", i.Date))
+		} else if i.Date > today {
+			problems = append(problems, fmt.Sprintf("date %q is in the future", i.Date))
+		} else if i.Date < first {
+			problems = append(problems, fmt.Sprintf("date %q is before the first day %s", i.Date, first))
+		}
+	}
+
+	for _, p := range i.Phases {
+		if !lo.Contains(AllPhases, p) {
+			problems = append(problems, fmt.Sprintf("phase %q is unknown, must be one of %v", p, AllPhases))
+		}
+	}
+	if dupes := lo.FindDuplicates(i.Phases); len(dupes) > 0 {
+		problems = append(problems, fmt.Sprintf("phases %v are repeated", dupes))
+	}
+
+	problems = append(problems, checkImage("", Variant{i.Model, i.Prompt, i.Seed}, models)...)
+
+	if len(i.Variants) > maxVariants {
+		problems = append(problems, fmt.Sprintf("%d variants requested, must be at most %d", len(i.Variants), maxVariants))
+	}
+	for n, v := range i.Variants {
+		problems = append(problems, checkImage(fmt.Sprintf("variant %d ", n+1), v, models)...)
+	}
+	if i.Pick != 0 && (i.Pick < 1 || i.Pick > max(len(i.Variants), 1)) {
+		problems = append(problems, fmt.Sprintf("pick %d must name one of the %d images", i.Pick, max(len(i.Variants), 1)))
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{problems}
+	}
+	return nil
+}
+
+// checkImage validates the model, prompt and seed of one image, prefixing each problem.
+func checkImage(prefix string, v Variant, models []string) []string {
+	var problems []string
+
+	if v.Model != "" && len(models) > 0 && !lo.Contains(models, v.Model) {
+		problems = append(problems, fmt.Sprintf("%smodel %q is not supported by the image provider", prefix, v.Model))
+	}
+
+	if n := utf8.RuneCountInString(v.Prompt); n > maxPromptLength {
+		problems = append(problems, fmt.Sprintf("%sprompt is %d characters, must be at most %d", prefix, n, maxPromptLength))
+	}
+
+	if v.Seed != "" {
+		if _, err := strconv.ParseUint(v.Seed, 10, 32); err != nil {
+			problems = append(problems, fmt.Sprintf("%sseed %q must be a non-negative 32-bit integer", prefix, v.Seed))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,79 @@
+package handler_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dmorgan81/kittenbot/internal/handler"
+)
+
+const (
+	firstDay = "20230720"
+	today    = "20231201"
+)
+
+var models = []string{"icbinp", "cyberrealistic_1_3"}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input handler.Input
+		want  string
+	}{
+		{name: "empty"},
+		{name: "first day", input: handler.Input{Date: firstDay}},
+		{name: "before the first day", input: handler.Input{Date: "20230719"}, want: `date "20230719" is before the first day 20230720`},
+		{name: "today", input: handler.Input{Date: today}},
+		{name: "tomorrow", input: handler.Input{Date: "20231202"}, want: `date "20231202" is in the future`},
+		{name: "malformed date", input: handler.Input{Date: "2023-12-01"}, want: `date "2023-12-01" must be formatted as YYYYMMDD`},
+		{name: "known phases", input: handler.Input{Phases: handler.AllPhases}},
+		{name: "unknown phase", input: handler.Input{Phases: []handler.Phase{"paint"}}, want: `phase "paint" is unknown`},
+		{name: "repeated phase", input: handler.Input{Phases: []handler.Phase{handler.PhaseImage, handler.PhaseImage}}, want: "phases [image] are repeated"},
+		{name: "known model", input: handler.Input{Model: "icbinp"}},
+		{name: "unknown model", input: handler.Input{Model: "dall-e"}, want: `model "dall-e" is not supported`},
+		{name: "longest prompt", input: handler.Input{Prompt: strings.Repeat("ü", 1000)}},
+		{name: "prompt too long", input: handler.Input{Prompt: strings.Repeat("ü", 1001)}, want: "prompt is 1001 characters, must be at most 1000"},
+		{name: "largest seed", input: handler.Input{Seed: "4294967295"}},
+		{name: "seed too large", input: handler.Input{Seed: "4294967296"}, want: `seed "4294967296" must be a non-negative 32-bit integer`},
+		{name: "negative seed", input: handler.Input{Seed: "-1"}, want: `seed "-1" must be a non-negative 32-bit integer`},
+		{name: "most variants", input: handler.Input{Variants: make([]handler.Variant, 10), Pick: 10}},
+		{name: "too many variants", input: handler.Input{Variants: make([]handler.Variant, 11)}, want: "11 variants requested, must be at most 10"},
+		{name: "bad variant", input: handler.Input{Variants: []handler.Variant{{}, {Model: "dall-e"}}}, want: `variant 2 model "dall-e" is not supported`},
+		{name: "pick past the variants", input: handler.Input{Variants: make([]handler.Variant, 2), Pick: 3}, want: "pick 3 must name one of the 2 images"},
+		{name: "pick without variants", input: handler.Input{Pick: 2}, want: "pick 2 must name one of the 1 images"},
+	}
+	for _, tt := range tests {
+		err := tt.input.Validate(firstDay, today, models)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: got %v, want no error", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestValidateAggregates reports every problem with an input together.
+func TestValidateAggregates(t *testing.T) {
+	input := handler.Input{
+		Date:   "20230101",
+		Phases: []handler.Phase{"paint"},
+		Model:  "dall-e",
+		Seed:   "kitten",
+	}
+	var verr *handler.ValidationError
+	if err := input.Validate(firstDay, today, models); !errors.As(err, &verr) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	if len(verr.Problems) != 4 {
+		t.Errorf("got problems %q, want one each for the date, phase, model and seed", verr.Problems)
+	}
+}
+
+// TestValidateWithoutModels skips the model check when the provider does not list them.
+func TestValidateWithoutModels(t *testing.T) {
+	if err := (handler.Input{Model: "dall-e"}).Validate(firstDay, today, nil); err != nil {
+		t.Errorf("got %v, want no error", err)
+	}
+}
@@ -6,11 +6,34 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/dmorgan81/kittenbot/internal/log"
 	"github.com/samber/do"
 )
 
+// Headers api.dezgo.com describes each request's price and the account's remaining credit in.
+const (
+	dezgoCostHeader    = "X-Credits-Cost"
+	dezgoBalanceHeader = "X-Credits-Balance"
+)
+
+// dezgoModels are the text2image models accepted by api.dezgo.com.
+var dezgoModels = []string{
+	"absolute_reality_1_8_1",
+	"cyberrealistic_1_3",
+	"cyberrealistic_3_3",
+	"dreamshaper_8",
+	"epic_realism",
+	"foto_assisted_diffusion",
+	"icbinp",
+	"realistic_vision_5_1",
+	"stable_diffusion_1_5",
+	"stable_diffusion_2_1",
+	"stable_diffusion_papercut",
+	"stable_diffusion_voxelart",
+}
+
 type DezgoGenerator struct {
 	client *http.Client
 	key    string
@@ -18,22 +41,29 @@ type DezgoGenerator struct {
 
 func NewDezgoGenerator(i *do.Injector) (Generator, error) {
 	client := &http.Client{}
-	key := do.MustInvokeNamed[string](i, "dezgo_key")
+	key, err := do.InvokeNamed[string](i, "dezgo_key")
+	if err != nil {
+		return nil, err
+	}
 	return &DezgoGenerator{client, key}, nil
 }
 
-func (g *DezgoGenerator) Generate(ctx context.Context, params Params) ([]byte, string, error) {
+func (g *DezgoGenerator) Models() []string {
+	return dezgoModels
+}
+
+func (g *DezgoGenerator) Generate(ctx context.Context, params Params) (Result, error) {
 	log := log.FromContextOrDiscard(ctx).WithGroup("dezgo").With("params", params)
 	log.Info("generating image via api.dezgo.com")
 
 	body, err := json.Marshal(params)
 	if err != nil {
-		return nil, "", err
+		return Result{}, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.dezgo.com/text2image", bytes.NewReader(body))
 	if err != nil {
-		return nil, "", err
+		return Result{}, err
 	}
 
 	req.Header.Add("Content-Type", "application/json")
@@ -41,17 +71,28 @@ func (g *DezgoGenerator) Generate(ctx context.Context, params Params) ([]byte, s
 
 	resp, err := g.client.Do(req)
 	if err != nil {
-		return nil, "", err
+		return Result{}, err
 	}
 	defer resp.Body.Close()
 
-	seed := resp.Header.Get("x-input-seed")
-	log.Info("received image via api.dezgo.com", "seed", seed)
+	result := Result{
+		Seed:    resp.Header.Get("x-input-seed"),
+		Cost:    credits(resp.Header.Get(dezgoCostHeader)),
+		Balance: credits(resp.Header.Get(dezgoBalanceHeader)),
+	}
+	log.Info("received image via api.dezgo.com", "seed", result.Seed)
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, "", err
+	if result.Data, err = io.ReadAll(resp.Body); err != nil {
+		return Result{}, err
 	}
+	return result, nil
+}
 
-	return data, seed, nil
+// credits parses an amount of credit from a header, nil when it is missing or malformed.
+func credits(v string) *float64 {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil
+	}
+	return &f
 }
@@ -8,6 +8,21 @@ type Params struct {
 	Seed   string `json:"seed,omitempty"`
 }
 
+// Result is a generated image and the seed it was generated with. Cost is what the provider
+// charged for it and Balance the credit left on the account afterwards, nil when the
+// provider does not say.
+type Result struct {
+	Data    []byte
+	Seed    string
+	Cost    *float64
+	Balance *float64
+}
+
 type Generator interface {
-	Generate(context.Context, Params) ([]byte, string, error)
+	Generate(context.Context, Params) (Result, error)
+}
+
+// ModelLister is implemented by generators that know which models their provider accepts.
+type ModelLister interface {
+	Models() []string
 }
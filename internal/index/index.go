@@ -0,0 +1,133 @@
+package index
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+)
+
+// statConcurrency caps how many objects are looked up at once when listing every day.
+const statConcurrency = 16
+
+// Day is a published day, described by the metadata of its page.
+type Day struct {
+	Key          string
+	Date         time.Time
+	Metadata     map[string]string
+	LastModified time.Time
+}
+
+// Page returns the name of the day's page.
+func (d Day) Page() string {
+	return d.Key + ".html"
+}
+
+// Image returns the name of the day's pick.
+func (d Day) Image() string {
+	pick, _ := strconv.Atoi(d.Metadata["pick"])
+	return day.Name(d.Key, pick, ".png")
+}
+
+// Images returns the names of every image of the day.
+func (d Day) Images() []string {
+	variants, _ := strconv.Atoi(d.Metadata["variants"])
+	if variants == 0 {
+		return []string{d.Key + ".png"}
+	}
+	return lo.Times(variants, func(n int) string {
+		return day.Name(d.Key, n+1, ".png")
+	})
+}
+
+// Index finds published days by their pages in the store.
+type Index struct {
+	reader   store.Reader
+	calendar *day.Calendar
+}
+
+func NewIndex(i *do.Injector) (*Index, error) {
+	reader, err := do.Invoke[store.Reader](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Index{reader, do.MustInvoke[*day.Calendar](i)}, nil
+}
+
+// Keys returns the key of every published day, oldest first.
+func (x *Index) Keys(ctx context.Context) ([]string, error) {
+	names, err := x.reader.List(ctx, store.ListParams{Delimiter: "/"})
+	if err != nil {
+		return nil, err
+	}
+	keys := lo.FilterMap(names, func(name string, _ int) (string, bool) {
+		return day.FromName(name, ".html")
+	})
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Day looks up a single published day.
+func (x *Index) Day(ctx context.Context, key string) (Day, error) {
+	obj, err := x.reader.Stat(ctx, key+".html")
+	if err != nil {
+		return Day{}, err
+	}
+	date, err := x.calendar.Parse(key)
+	if err != nil {
+		return Day{}, err
+	}
+	return Day{Key: key, Date: date, Metadata: obj.Metadata, LastModified: obj.LastModified}, nil
+}
+
+// Days returns every published day, oldest first.
+func (x *Index) Days(ctx context.Context) ([]Day, error) {
+	keys, err := x.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]Day, len(keys))
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(statConcurrency)
+	for n, key := range keys {
+		n, key := n, key
+		group.Go(func() (err error) {
+			days[n], err = x.Day(ctx, key)
+			return err
+		})
+	}
+	return days, group.Wait()
+}
+
+// Neighbours returns the published days either side of key, nil where there are none.
+func (x *Index) Neighbours(ctx context.Context, key string) (*Day, *Day, error) {
+	keys, err := x.Keys(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var previous, next *Day
+	before := lo.Filter(keys, func(k string, _ int) bool { return k < key })
+	if len(before) > 0 {
+		d, err := x.Day(ctx, before[len(before)-1])
+		if err != nil {
+			return nil, nil, err
+		}
+		previous = &d
+	}
+	if k, ok := lo.Find(keys, func(k string) bool { return k > key }); ok {
+		d, err := x.Day(ctx, k)
+		if err != nil {
+			return nil, nil, err
+		}
+		next = &d
+	}
+	return previous, next, nil
+}
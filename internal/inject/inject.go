@@ -3,26 +3,42 @@ package inject
 import (
 	"context"
 	"fmt"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/dmorgan81/kittenbot/internal/api"
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/config"
+	"github.com/dmorgan81/kittenbot/internal/day"
 	"github.com/dmorgan81/kittenbot/internal/feed"
 	"github.com/dmorgan81/kittenbot/internal/handler"
 	"github.com/dmorgan81/kittenbot/internal/image"
+	"github.com/dmorgan81/kittenbot/internal/index"
+	"github.com/dmorgan81/kittenbot/internal/ledger"
+	"github.com/dmorgan81/kittenbot/internal/lock"
 	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/moderate"
+	"github.com/dmorgan81/kittenbot/internal/notify"
 	"github.com/dmorgan81/kittenbot/internal/page"
 	"github.com/dmorgan81/kittenbot/internal/param"
 	"github.com/dmorgan81/kittenbot/internal/post"
 	"github.com/dmorgan81/kittenbot/internal/prompt"
+	"github.com/dmorgan81/kittenbot/internal/schedule"
+	"github.com/dmorgan81/kittenbot/internal/server"
+	"github.com/dmorgan81/kittenbot/internal/sitemap"
 	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/dmorgan81/kittenbot/internal/verify"
 	"github.com/samber/do"
+	"github.com/samber/lo"
 )
 
-func Setup(ctx context.Context) *do.Injector {
+func Setup(ctx context.Context, cfg *config.Config) *do.Injector {
 	log := log.FromContextOrDiscard(ctx)
 
 	injector := do.NewWithOpts(&do.InjectorOpts{
@@ -30,51 +46,294 @@ func Setup(ctx context.Context) *do.Injector {
 			log.Info(fmt.Sprintf(format, args))
 		},
 	})
+	do.ProvideValue[*config.Config](injector, cfg)
+	do.ProvideValue[clock.Clock](injector, time.Now)
+	do.Provide[*day.Calendar](injector, func(i *do.Injector) (*day.Calendar, error) {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		return day.NewCalendar(loc), nil
+	})
 	do.Provide[aws.Config](injector, func(i *do.Injector) (aws.Config, error) {
-		return config.LoadDefaultConfig(ctx)
+		return awsconfig.LoadDefaultConfig(ctx)
 	})
 	do.Provide[*ssm.Client](injector, func(i *do.Injector) (*ssm.Client, error) {
-		return ssm.NewFromConfig(do.MustInvoke[aws.Config](i)), nil
+		awsCfg, err := do.Invoke[aws.Config](i)
+		if err != nil {
+			return nil, err
+		}
+		return ssm.NewFromConfig(awsCfg), nil
+	})
+	do.Provide[*secretsmanager.Client](injector, func(i *do.Injector) (*secretsmanager.Client, error) {
+		awsCfg, err := do.Invoke[aws.Config](i)
+		if err != nil {
+			return nil, err
+		}
+		return secretsmanager.NewFromConfig(awsCfg), nil
 	})
 	do.Provide[*s3.Client](injector, func(i *do.Injector) (*s3.Client, error) {
-		return s3.NewFromConfig(do.MustInvoke[aws.Config](i)), nil
+		awsCfg, err := do.Invoke[aws.Config](i)
+		if err != nil {
+			return nil, err
+		}
+		return s3.NewFromConfig(awsCfg), nil
 	})
 	do.Provide[*cloudfront.Client](injector, func(i *do.Injector) (*cloudfront.Client, error) {
-		return cloudfront.NewFromConfig(do.MustInvoke[aws.Config](i)), nil
+		awsCfg, err := do.Invoke[aws.Config](i)
+		if err != nil {
+			return nil, err
+		}
+		return cloudfront.NewFromConfig(awsCfg), nil
 	})
 
-	do.Provide[param.Fetcher](injector, param.NewParameterStoreFetcher)
+	do.Provide[param.Fetcher](injector, fetcher(cfg.ParamSources))
 	do.Provide[*prompt.Randomizer](injector, prompt.NewRandomizer)
-	do.Provide[image.Generator](injector, image.NewDezgoGenerator)
+	do.Provide[image.Generator](injector, ledger.Meter(image.NewDezgoGenerator))
 	do.Provide[store.Uploader](injector, store.NewS3Uploader)
-	do.Provide[store.Invalidator](injector, store.NewCloudFrontInvalidator)
+	do.Provide[store.Copier](injector, store.NewS3Copier)
+	do.Provide[store.Deleter](injector, store.NewS3Deleter)
+	do.Provide[store.Reader](injector, store.NewS3Reader)
+	do.Provide[store.Invalidator](injector, invalidator(cfg.CDN))
+	do.Provide[lock.Locker](injector, lock.NewLocker)
+	do.Provide[*index.Index](injector, index.NewIndex)
 	do.Provide[*page.Templator](injector, page.NewTemplator)
-	do.Provide[*feed.Generator](injector, feed.NewS3Generator)
-	do.Provide[post.Poster](injector, post.NewRedditPoster)
+	do.Provide[*feed.Generator](injector, feed.NewGenerator)
+	do.Provide[*sitemap.Generator](injector, sitemap.NewGenerator)
+	do.Provide[*api.Generator](injector, api.NewGenerator)
+	do.Provide[post.Poster](injector, func(i *do.Injector) (post.Poster, error) {
+		if err := cfg.ValidatePosting(); err != nil {
+			return nil, err
+		}
+		return post.NewRedditPoster(i)
+	})
+	do.Provide[moderate.Moderator](injector, moderator(cfg.Moderation))
+	do.Provide[notify.Notifier](injector, notifier(cfg.Notify))
 
 	do.ProvideNamed[string](injector, "dezgo_key", func(i *do.Injector) (string, error) {
-		return do.MustInvoke[param.Fetcher](i).Fetch(ctx, os.Getenv("DEZGO_KEY_PARAM"))
+		return fetch(ctx, i, cfg.DezgoKeyParam)
 	})
 	do.ProvideNamed[[]string](injector, "prompts", func(i *do.Injector) ([]string, error) {
-		return do.MustInvoke[param.Fetcher](i).FetchAll(ctx, os.Getenv("PROMPTS_PARAM"))
+		fetcher, err := do.Invoke[param.Fetcher](i)
+		if err != nil {
+			return nil, err
+		}
+		params, err := fetcher.FetchAll(ctx, cfg.PromptsParam)
+		return param.Values(params), err
+	})
+	do.ProvideNamed[[]string](injector, "moderation_blocklist", func(i *do.Injector) ([]string, error) {
+		fetcher, err := do.Invoke[param.Fetcher](i)
+		if err != nil {
+			return nil, err
+		}
+		params, err := fetcher.FetchAll(ctx, cfg.ModerationBlocklistParam)
+		return param.Values(params), err
 	})
 	do.ProvideNamed[string](injector, "reddit_client_id", func(i *do.Injector) (string, error) {
-		return do.MustInvoke[param.Fetcher](i).Fetch(ctx, os.Getenv("REDDIT_CLIENT_ID_PARAM"))
+		return fetch(ctx, i, cfg.RedditClientIDParam)
 	})
 	do.ProvideNamed[string](injector, "reddit_client_secret", func(i *do.Injector) (string, error) {
-		return do.MustInvoke[param.Fetcher](i).Fetch(ctx, os.Getenv("REDDIT_CLIENT_SECRET_PARAM"))
+		return fetch(ctx, i, cfg.RedditClientSecretParam)
 	})
 	do.ProvideNamed[string](injector, "reddit_password", func(i *do.Injector) (string, error) {
-		return do.MustInvoke[param.Fetcher](i).Fetch(ctx, os.Getenv("REDDIT_PASSWORD_PARAM"))
+		return fetch(ctx, i, cfg.RedditPasswordParam)
 	})
 	do.ProvideNamed[string](injector, "reddit_username", func(i *do.Injector) (string, error) {
-		return do.MustInvoke[param.Fetcher](i).Fetch(ctx, os.Getenv("REDDIT_USERNAME_PARAM"))
+		return fetch(ctx, i, cfg.RedditUsernameParam)
+	})
+	do.ProvideNamed[string](injector, "cloudflare_token", func(i *do.Injector) (string, error) {
+		return fetch(ctx, i, cfg.CloudflareTokenParam)
+	})
+	do.ProvideNamed[string](injector, "fastly_key", func(i *do.Injector) (string, error) {
+		return fetch(ctx, i, cfg.FastlyKeyParam)
 	})
-	do.ProvideNamedValue[string](injector, "bucket", os.Getenv("BUCKET"))
-	do.ProvideNamedValue[string](injector, "distribution", os.Getenv("DISTRIBUTION"))
-	do.ProvideNamedValue[string](injector, "subreddit", os.Getenv("SUBREDDIT"))
+	do.ProvideNamed[string](injector, "admin_token", func(i *do.Injector) (string, error) {
+		return fetch(ctx, i, cfg.AdminTokenParam)
+	})
+	do.ProvideNamed[string](injector, "notify_discord_webhook", func(i *do.Injector) (string, error) {
+		return fetch(ctx, i, cfg.NotifyDiscordWebhookParam)
+	})
+	do.ProvideNamed[string](injector, "notify_slack_webhook", func(i *do.Injector) (string, error) {
+		return fetch(ctx, i, cfg.NotifySlackWebhookParam)
+	})
+	do.ProvideNamed[string](injector, "notify_smtp_password", func(i *do.Injector) (string, error) {
+		return fetch(ctx, i, cfg.NotifySMTPPasswordParam)
+	})
+	do.ProvideNamedValue[time.Duration](injector, "secrets_ttl", cfg.SecretsTTL)
+	do.ProvideNamedValue[bool](injector, "param_recursive", cfg.ParamRecursive)
+	do.ProvideNamedValue[string](injector, "site_url", cfg.SiteURL)
+	do.ProvideNamedValue[string](injector, "site_title", cfg.SiteTitle)
+	do.ProvideNamedValue[string](injector, "site_description", cfg.SiteDescription)
+	do.ProvideNamedValue[string](injector, "theme_dir", cfg.ThemeDir)
+	do.ProvideNamedValue[string](injector, "cloudflare_zone", cfg.CloudflareZone)
+	do.ProvideNamedValue[string](injector, "cloudflare_url", cfg.CloudflareURL)
+	do.ProvideNamedValue[string](injector, "fastly_url", cfg.FastlyURL)
+	do.ProvideNamedValue[string](injector, "bucket", cfg.Bucket)
+	do.ProvideNamedValue[string](injector, "storage_class", cfg.StorageClass)
+	do.ProvideNamedValue[string](injector, "distribution", cfg.Distribution)
+	do.ProvideNamedValue[time.Duration](injector, "invalidation_wait", cfg.InvalidationWait)
+	do.ProvideNamedValue[string](injector, "subreddit", cfg.Subreddit)
+	do.ProvideNamedValue[string](injector, "first_day", cfg.FirstDay)
+	do.ProvideNamedValue[int](injector, "variants", cfg.Variants)
+	do.ProvideNamedValue[float64](injector, "budget", cfg.Budget)
+	do.ProvideNamedValue[float64](injector, "balance_warning", cfg.BalanceWarning)
+	do.ProvideNamedValue[string](injector, "moderation_url", cfg.ModerationURL)
+	do.ProvideNamedValue[float64](injector, "moderation_threshold", cfg.ModerationThreshold)
+	do.ProvideNamedValue[int](injector, "moderation_attempts", cfg.ModerationAttempts)
+	do.ProvideNamedValue[int](injector, "dedup_days", cfg.DedupDays)
+	do.ProvideNamedValue[int](injector, "dedup_distance", cfg.DedupDistance)
+	do.ProvideNamedValue[int](injector, "verify_distance", cfg.VerifyDistance)
+	do.ProvideNamedValue[bool](injector, "staged", cfg.Staged)
+	do.ProvideNamedValue[bool](injector, "notify_success", cfg.NotifySuccess)
+	do.ProvideNamedValue[string](injector, "notify_webhook_url", cfg.NotifyWebhookURL)
+	do.ProvideNamedValue[string](injector, "notify_smtp_addr", cfg.NotifySMTPAddr)
+	do.ProvideNamedValue[string](injector, "notify_smtp_from", cfg.NotifySMTPFrom)
+	do.ProvideNamedValue[[]string](injector, "notify_smtp_to", lo.Map(strings.Split(cfg.NotifySMTPTo, ","), func(to string, _ int) string {
+		return strings.TrimSpace(to)
+	}))
+	do.ProvideNamedValue[string](injector, "notify_smtp_username", cfg.NotifySMTPUsername)
 
 	do.Provide[*handler.Handler](injector, handler.NewHandler)
+	do.Provide[*verify.Verifier](injector, verify.NewVerifier)
+	do.Provide[*server.Server](injector, server.NewServer)
+	do.Provide[*schedule.Scheduler](injector, schedule.NewScheduler)
 
 	return injector
 }
+
+func fetch(ctx context.Context, i *do.Injector, path string) (string, error) {
+	fetcher, err := do.Invoke[param.Fetcher](i)
+	if err != nil {
+		return "", err
+	}
+	return fetcher.Fetch(ctx, path)
+}
+
+// fetcher builds a param.Fetcher from a comma separated list of sources, tried in order:
+// ssm, secretsmanager, env, dotenv=<file> and file=<yaml or json file>. A parameter missing
+// from one source is looked up in the next; any other failure stops the lookup.
+func fetcher(sources string) do.Provider[param.Fetcher] {
+	return func(i *do.Injector) (param.Fetcher, error) {
+		var fetchers []param.Fetcher
+		for _, source := range strings.Split(sources, ",") {
+			kind, arg, _ := strings.Cut(strings.TrimSpace(source), "=")
+
+			var f param.Fetcher
+			var err error
+			switch kind {
+			case "ssm":
+				f, err = param.NewParameterStoreFetcher(i)
+			case "secretsmanager":
+				f, err = param.NewSecretsManagerFetcher(i)
+			case "env":
+				f, err = param.NewEnvFetcher()
+			case "dotenv":
+				f, err = param.NewDotenvFetcher(lo.Ternary(arg != "", arg, ".env"))
+			case "file":
+				f, err = param.NewFileFetcher(arg)
+			default:
+				err = fmt.Errorf("unknown parameter source %q, must be one of ssm, secretsmanager, env, dotenv or file", kind)
+			}
+			if err != nil {
+				return nil, err
+			}
+			fetchers = append(fetchers, f)
+		}
+
+		if len(fetchers) == 1 {
+			return fetchers[0], nil
+		}
+		return param.NewChainFetcher(fetchers...), nil
+	}
+}
+
+// moderator builds a moderate.Moderator from a comma separated list of moderators, asked in
+// order: classifier, blocklist, dedup or none.
+func moderator(kinds string) do.Provider[moderate.Moderator] {
+	return func(i *do.Injector) (moderate.Moderator, error) {
+		var moderators []moderate.Moderator
+		for _, kind := range strings.Split(kinds, ",") {
+			var m moderate.Moderator
+			var err error
+			switch strings.TrimSpace(kind) {
+			case "classifier":
+				m, err = moderate.NewClassifierModerator(i)
+			case "blocklist":
+				m, err = moderate.NewBlocklistModerator(i)
+			case "dedup":
+				m, err = moderate.NewDedupModerator(i)
+			case "none":
+				continue
+			default:
+				err = fmt.Errorf("unknown moderator %q, must be one of classifier, blocklist, dedup or none", kind)
+			}
+			if err != nil {
+				return nil, err
+			}
+			moderators = append(moderators, m)
+		}
+
+		switch len(moderators) {
+		case 0:
+			return moderate.NewNoopModerator(i)
+		case 1:
+			return moderators[0], nil
+		}
+		return moderate.NewChainModerator(moderators...), nil
+	}
+}
+
+// notifier builds a notify.Notifier from a comma separated list of notifiers, each told
+// about every event: smtp, webhook, discord, slack or none.
+func notifier(kinds string) do.Provider[notify.Notifier] {
+	return func(i *do.Injector) (notify.Notifier, error) {
+		var notifiers []notify.Notifier
+		for _, kind := range strings.Split(kinds, ",") {
+			var n notify.Notifier
+			var err error
+			switch strings.TrimSpace(kind) {
+			case "smtp":
+				n, err = notify.NewSMTPNotifier(i)
+			case "webhook":
+				n, err = notify.NewWebhookNotifier(i)
+			case "discord":
+				n, err = notify.NewDiscordNotifier(i)
+			case "slack":
+				n, err = notify.NewSlackNotifier(i)
+			case "none":
+				continue
+			default:
+				err = fmt.Errorf("unknown notifier %q, must be one of smtp, webhook, discord, slack or none", kind)
+			}
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, n)
+		}
+
+		switch len(notifiers) {
+		case 0:
+			return notify.NewNoopNotifier(i)
+		case 1:
+			return notifiers[0], nil
+		}
+		return notify.NewChainNotifier(notifiers...), nil
+	}
+}
+
+func invalidator(cdn string) do.Provider[store.Invalidator] {
+	switch cdn {
+	case "cloudfront":
+		return store.NewCloudFrontInvalidator
+	case "cloudflare":
+		return store.NewCloudflareInvalidator
+	case "fastly":
+		return store.NewFastlyInvalidator
+	case "none":
+		return store.NewNoopInvalidator
+	default:
+		return func(*do.Injector) (store.Invalidator, error) {
+			return nil, fmt.Errorf("unknown CDN %q, must be one of cloudfront, cloudflare, fastly or none", cdn)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package inject_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/handler"
+	"github.com/dmorgan81/kittenbot/internal/inject"
+	"github.com/dmorgan81/kittenbot/internal/ledger"
+	"github.com/dmorgan81/kittenbot/internal/schedule"
+	"github.com/dmorgan81/kittenbot/internal/server"
+	"github.com/dmorgan81/kittenbot/internal/verify"
+	"github.com/samber/do"
+)
+
+// TestSetup runs the wiring a deployment uses, with only its external services replaced by
+// fakes, so wiring missing from Setup fails here even though fake.Injector.Build has it.
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+	f := fake.NewInjector(time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC))
+	f.Config.ParamSources = "env"
+	f.Config.Moderation = "dedup"
+	f.Config.ModerationThreshold = 0.8
+	f.Config.Notify = "none"
+	f.Config.Schedule = "30 0 * * *"
+	f.Config.AdminTokenParam = "/kittenbot/admin_token"
+	f.Fetcher["/kittenbot/admin_token"] = "secret"
+	f.Generator.Cost = 0.01
+
+	i := inject.Setup(ctx, f.Config)
+	f.Override(i)
+
+	for name, invoke := range map[string]func() error{
+		"verifier":  func() error { _, err := do.Invoke[*verify.Verifier](i); return err },
+		"server":    func() error { _, err := do.Invoke[*server.Server](i); return err },
+		"scheduler": func() error { _, err := do.Invoke[*schedule.Scheduler](i); return err },
+	} {
+		if err := invoke(); err != nil {
+			t.Errorf("building %s: %v", name, err)
+		}
+	}
+
+	h, err := do.Invoke[*handler.Handler](i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Handle(ctx, handler.Input{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"20231201.png", "20231201.html", "20231201.json", "latest.html", "api/index.json"} {
+		if _, ok := f.Store.Get(name); !ok {
+			t.Errorf("%s was not published", name)
+		}
+	}
+	if len(f.Poster.Posts) != 1 {
+		t.Errorf("got %d posts, want 1", len(f.Poster.Posts))
+	}
+	month, err := ledger.Load(ctx, f.Store, "2023-12")
+	if err != nil || len(month.Entries) != 1 {
+		t.Errorf("got ledger %+v, %v, want the image metered", month, err)
+	}
+}
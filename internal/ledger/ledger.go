@@ -0,0 +1,218 @@
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/image"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrBudgetExceeded is returned instead of generating an image that would take the month's
+// spending past the budget.
+var ErrBudgetExceeded = errors.New("monthly budget exceeded")
+
+// Entry is a single generation the provider charged for.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Model  string    `json:"model"`
+	Prompt string    `json:"prompt"`
+	Seed   string    `json:"seed"`
+	Cost   float64   `json:"cost"`
+}
+
+// Month is a month's ledger: every entry recorded for it, oldest first, and their total.
+type Month struct {
+	Month   string  `json:"month"`
+	Total   float64 `json:"total"`
+	Entries []Entry `json:"entries"`
+}
+
+// readConcurrency caps how many entries are read at once.
+const readConcurrency = 16
+
+// Prefix returns the prefix the entries for month, formatted as YYYY-MM, are stored under.
+func Prefix(month string) string {
+	return "ledger/" + month + "/"
+}
+
+// EntryName returns the name of an entry's own object. Each entry is written once to a name
+// of its own, so runs sharing a bucket never overwrite each other's entries.
+func EntryName(month string, e Entry) string {
+	return Prefix(month) + e.Time.UTC().Format("20060102T150405.000000000Z") + "-" + e.Seed + ".json"
+}
+
+// MeteredGenerator records what each image costs in the month's ledger and refuses to
+// generate once the month's spending would pass the budget. A budget of 0 is unlimited.
+// Images the provider does not report a cost for are not recorded. An entry that cannot be
+// written is kept and written before the next image is generated, which is refused until it
+// has been, so the budget is never enforced against an incomplete ledger.
+type MeteredGenerator struct {
+	mu        sync.Mutex
+	generator image.Generator
+	reader    store.Reader
+	uploader  store.Uploader
+	now       clock.Clock
+	calendar  *day.Calendar
+	budget    float64
+	warning   float64
+	unsaved   []Entry
+	uncosted  sync.Once
+}
+
+// Meter wraps the generator built by provider in a MeteredGenerator.
+func Meter(provider do.Provider[image.Generator]) do.Provider[image.Generator] {
+	return func(i *do.Injector) (image.Generator, error) {
+		generator, err := provider(i)
+		if err != nil {
+			return nil, err
+		}
+		return NewMeteredGenerator(i, generator)
+	}
+}
+
+func NewMeteredGenerator(i *do.Injector, generator image.Generator) (*MeteredGenerator, error) {
+	reader, err := do.Invoke[store.Reader](i)
+	if err != nil {
+		return nil, err
+	}
+	uploader, err := do.Invoke[store.Uploader](i)
+	if err != nil {
+		return nil, err
+	}
+	return &MeteredGenerator{
+		generator: generator,
+		reader:    reader,
+		uploader:  uploader,
+		now:       do.MustInvoke[clock.Clock](i),
+		calendar:  do.MustInvoke[*day.Calendar](i),
+		budget:    do.MustInvokeNamed[float64](i, "budget"),
+		warning:   do.MustInvokeNamed[float64](i, "balance_warning"),
+	}, nil
+}
+
+// Models lists the wrapped generator's models, if it knows them.
+func (g *MeteredGenerator) Models() []string {
+	if lister, ok := g.generator.(image.ModelLister); ok {
+		return lister.Models()
+	}
+	return nil
+}
+
+func (g *MeteredGenerator) Generate(ctx context.Context, params image.Params) (image.Result, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("ledger")
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.flush(ctx); err != nil {
+		return image.Result{}, fmt.Errorf("recording earlier costs in ledger: %w", err)
+	}
+
+	now := g.calendar.In(g.now())
+	month, err := Load(ctx, g.reader, now.Format("2006-01"))
+	if err != nil {
+		return image.Result{}, err
+	}
+	// The last image's cost stands in for what the next one will cost.
+	if g.budget > 0 && len(month.Entries) > 0 {
+		next := month.Entries[len(month.Entries)-1].Cost
+		if month.Total+next > g.budget {
+			return image.Result{}, fmt.Errorf("%w: spent %g of %g in %s", ErrBudgetExceeded, month.Total, g.budget, month.Month)
+		}
+	}
+
+	result, err := g.generator.Generate(ctx, params)
+	if err != nil {
+		return result, err
+	}
+	if result.Balance != nil && *result.Balance < g.warning {
+		log.Warn("credit balance is low", "balance", *result.Balance, "warning", g.warning)
+	}
+	if result.Cost == nil {
+		g.uncosted.Do(func() {
+			log.Warn("provider did not report what the image cost, so it is not recorded or counted towards the budget", "model", params.Model)
+		})
+		return result, nil
+	}
+
+	g.unsaved = append(g.unsaved, Entry{
+		Time:   now,
+		Model:  params.Model,
+		Prompt: params.Prompt,
+		Seed:   result.Seed,
+		Cost:   *result.Cost,
+	})
+	// The image is paid for by now, so a ledger that cannot be saved must not lose it.
+	if err := g.flush(ctx); err != nil {
+		log.Error("recording cost in ledger, refusing to generate until it is", "error", err)
+	}
+	return result, nil
+}
+
+// flush writes the entries not yet saved, keeping any it could not write.
+func (g *MeteredGenerator) flush(ctx context.Context) error {
+	var errs []error
+	var unsaved []Entry
+	for _, e := range g.unsaved {
+		if err := g.save(ctx, e); err != nil {
+			errs = append(errs, err)
+			unsaved = append(unsaved, e)
+		}
+	}
+	g.unsaved = unsaved
+	return errors.Join(errs...)
+}
+
+func (g *MeteredGenerator) save(ctx context.Context, e Entry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return g.uploader.Upload(context.WithoutCancel(ctx), store.UploadParams{
+		Name:         EntryName(e.Time.Format("2006-01"), e),
+		Data:         data,
+		ContentType:  "application/json",
+		CacheControl: store.CacheNone,
+	})
+}
+
+// Load reads the ledger for month, formatted as YYYY-MM, from its entries.
+func Load(ctx context.Context, reader store.Reader, month string) (Month, error) {
+	m := Month{Month: month}
+	names, err := reader.List(ctx, store.ListParams{Prefix: Prefix(month)})
+	if err != nil {
+		return m, err
+	}
+
+	entries := make([]Entry, len(names))
+	group, gctx := errgroup.WithContext(ctx)
+	group.SetLimit(readConcurrency)
+	for n, name := range names {
+		n, name := n, name
+		group.Go(func() error {
+			data, err := reader.Read(gctx, name)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(data, &entries[n])
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return m, err
+	}
+	for _, e := range entries {
+		m.Total += e.Cost
+	}
+	m.Entries = entries
+	return m, nil
+}
@@ -0,0 +1,102 @@
+package ledger_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/image"
+	"github.com/dmorgan81/kittenbot/internal/ledger"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/do"
+)
+
+func TestMeteredGenerator(t *testing.T) {
+	var logs bytes.Buffer
+	ctx := log.NewContext(context.Background(), log.New(&logs))
+
+	f := fake.NewInjector(time.Date(2023, 12, 31, 23, 30, 0, 0, time.UTC))
+	f.Config.Timezone = "Europe/Berlin"
+	f.Config.Budget = 0.025
+	f.Config.BalanceWarning = 1
+	f.Generator.Cost = 0.01
+	f.Generator.Balance = 0.75
+	generator := do.MustInvoke[image.Generator](f.Build())
+
+	for _, seed := range []string{"1", "2"} {
+		if _, err := generator.Generate(ctx, image.Params{Model: "icbinp", Prompt: "cute kitten", Seed: seed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := generator.Generate(ctx, image.Params{Model: "icbinp", Prompt: "cute kitten", Seed: "3"}); !errors.Is(err, ledger.ErrBudgetExceeded) {
+		t.Fatalf("got %v, want ErrBudgetExceeded", err)
+	}
+	if calls := len(f.Generator.Calls); calls != 2 {
+		t.Errorf("generated %d images, want 2", calls)
+	}
+
+	// It is already January in the site's timezone.
+	month, err := ledger.Load(ctx, f.Store, "2024-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if month.Total != 0.02 || len(month.Entries) != 2 || month.Entries[1].Seed != "2" {
+		t.Errorf("got ledger %+v", month)
+	}
+	if !strings.Contains(logs.String(), "credit balance is low") {
+		t.Errorf("no low balance warning was logged:\n%s", logs.String())
+	}
+}
+
+// TestUnsavedEntry refuses to generate while an image's cost cannot be recorded, and records
+// it once the store recovers.
+func TestUnsavedEntry(t *testing.T) {
+	ctx := context.Background()
+	f := fake.NewInjector(time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC))
+	f.Generator.Cost = 0.01
+	generator := do.MustInvoke[image.Generator](f.Build())
+
+	entry := ledger.EntryName("2023-12", ledger.Entry{Time: f.Now, Seed: "1"})
+	f.Store.Fail["upload:"+entry] = errors.New("boom")
+	if _, err := generator.Generate(ctx, image.Params{Seed: "1"}); err != nil {
+		t.Fatalf("a paid for image was lost: %v", err)
+	}
+	if _, err := generator.Generate(ctx, image.Params{Seed: "2"}); err == nil {
+		t.Fatal("generated while the ledger was missing an entry")
+	}
+
+	delete(f.Store.Fail, "upload:"+entry)
+	if _, err := generator.Generate(ctx, image.Params{Seed: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	month, err := ledger.Load(ctx, f.Store, "2023-12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if month.Total != 0.02 || len(month.Entries) != 2 || month.Entries[0].Seed != "1" {
+		t.Errorf("got ledger %+v", month)
+	}
+	if calls := len(f.Generator.Calls); calls != 2 {
+		t.Errorf("generated %d images, want 2", calls)
+	}
+}
+
+func TestUncostedWarnsOnce(t *testing.T) {
+	var logs bytes.Buffer
+	ctx := log.NewContext(context.Background(), log.New(&logs))
+	f := fake.NewInjector(time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC))
+	generator := do.MustInvoke[image.Generator](f.Build())
+
+	for _, seed := range []string{"1", "2"} {
+		if _, err := generator.Generate(ctx, image.Params{Seed: seed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(logs.String(), "did not report what the image cost"); n != 1 {
+		t.Errorf("warned %d times, want once:\n%s", n, logs.String())
+	}
+}
@@ -0,0 +1,165 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+)
+
+const (
+	settle = 5 * time.Second
+	// RunTTL outlasts any run, so a lock a crashed run never released only blocks for a while.
+	RunTTL = time.Hour
+)
+
+// ErrHeld is returned when a run cannot start because another holds its day's lock.
+var ErrHeld = errors.New("another run for this day is in progress")
+
+// Locker ensures only one run acts on a key at a time. Locks expire after their TTL so a
+// crashed holder doesn't block later runs forever.
+type Locker interface {
+	Lock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	Unlock(ctx context.Context, key string) error
+}
+
+// StoreLocker keeps locks as objects in the store. An instance writes its owner token,
+// waits for competing writes to land, then reads the lock back; only the last writer sees
+// its own token. The store has no compare-and-swap, so this narrows the race rather than
+// closing it, which is enough for instances started minutes apart by the same schedule.
+// Within the process locks are exclusive outright.
+type StoreLocker struct {
+	mu       sync.Mutex
+	local    map[string]bool
+	uploader store.Uploader
+	reader   store.Reader
+	deleter  store.Deleter
+	now      clock.Clock
+	owner    string
+	settle   time.Duration
+}
+
+func NewLocker(i *do.Injector) (Locker, error) {
+	uploader, err := do.Invoke[store.Uploader](i)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := do.Invoke[store.Reader](i)
+	if err != nil {
+		return nil, err
+	}
+	deleter, err := do.Invoke[store.Deleter](i)
+	if err != nil {
+		return nil, err
+	}
+	return NewStoreLocker(uploader, reader, deleter, do.MustInvoke[clock.Clock](i), settle)
+}
+
+func NewStoreLocker(uploader store.Uploader, reader store.Reader, deleter store.Deleter, now clock.Clock, settle time.Duration) (*StoreLocker, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &StoreLocker{
+		local:    make(map[string]bool),
+		uploader: uploader,
+		reader:   reader,
+		deleter:  deleter,
+		now:      now,
+		owner:    hex.EncodeToString(b),
+		settle:   settle,
+	}, nil
+}
+
+func (l *StoreLocker) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	if l.local[key] {
+		l.mu.Unlock()
+		return false, nil
+	}
+	l.local[key] = true
+	l.mu.Unlock()
+
+	ok, err := l.lock(ctx, key, ttl)
+	if err != nil || !ok {
+		l.release(key)
+	}
+	return ok, err
+}
+
+func (l *StoreLocker) lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	name := "locks/" + key
+	held, err := l.held(ctx, name)
+	if err != nil || held {
+		return false, err
+	}
+
+	if err := l.uploader.Upload(ctx, store.UploadParams{
+		Name:        name,
+		Data:        []byte(l.owner),
+		ContentType: "text/plain",
+		Metadata: map[string]string{
+			"owner":   l.owner,
+			"expires": l.now().Add(ttl).UTC().Format(time.RFC3339),
+		},
+	}); err != nil {
+		return false, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-time.After(l.settle):
+	}
+
+	obj, err := l.reader.Stat(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return obj.Metadata["owner"] == l.owner, nil
+}
+
+// Unlock releases a lock this instance holds, leaving locks since taken by others alone.
+func (l *StoreLocker) Unlock(ctx context.Context, key string) error {
+	defer l.release(key)
+
+	name := "locks/" + key
+	obj, err := l.reader.Stat(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if obj.Metadata["owner"] != l.owner {
+		return nil
+	}
+	return l.deleter.Delete(ctx, name)
+}
+
+func (l *StoreLocker) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.local, key)
+}
+
+// held reports whether someone else holds an unexpired lock.
+func (l *StoreLocker) held(ctx context.Context, name string) (bool, error) {
+	obj, err := l.reader.Stat(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	expires, err := time.Parse(time.RFC3339, obj.Metadata["expires"])
+	if err != nil {
+		return false, nil
+	}
+	return obj.Metadata["owner"] != l.owner && l.now().Before(expires), nil
+}
@@ -0,0 +1,51 @@
+package lock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/lock"
+)
+
+func TestStoreLocker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	s := fake.NewStore(clock)
+
+	a, _ := lock.NewStoreLocker(s, s, s, clock, 0)
+	b, _ := lock.NewStoreLocker(s, s, s, clock, 0)
+
+	if ok, err := a.Lock(ctx, "20231201", time.Hour); err != nil || !ok {
+		t.Fatalf("first lock: got %v, %v", ok, err)
+	}
+	if ok, err := a.Lock(ctx, "20231201", time.Hour); err != nil || ok {
+		t.Fatalf("competing lock in the same instance: got %v, %v", ok, err)
+	}
+	if ok, err := b.Lock(ctx, "20231201", time.Hour); err != nil || ok {
+		t.Fatalf("competing lock: got %v, %v", ok, err)
+	}
+	if ok, err := b.Lock(ctx, "20231202", time.Hour); err != nil || !ok {
+		t.Fatalf("lock on another key: got %v, %v", ok, err)
+	}
+
+	if err := b.Unlock(ctx, "20231201"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := b.Lock(ctx, "20231201", time.Hour); err != nil || ok {
+		t.Fatalf("lock after someone else's unlock: got %v, %v", ok, err)
+	}
+	if err := a.Unlock(ctx, "20231201"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := b.Lock(ctx, "20231201", time.Hour); err != nil || !ok {
+		t.Fatalf("lock after unlock: got %v, %v", ok, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if ok, err := a.Lock(ctx, "20231202", time.Hour); err != nil || !ok {
+		t.Fatalf("lock after expiry: got %v, %v", ok, err)
+	}
+}
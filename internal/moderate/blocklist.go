@@ -0,0 +1,36 @@
+package moderate
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+// BlocklistModerator rejects images whose SHA-256 is on a list of known bad images.
+type BlocklistModerator struct {
+	hashes map[string]struct{}
+}
+
+func NewBlocklistModerator(i *do.Injector) (Moderator, error) {
+	hashes, err := do.InvokeNamed[[]string](i, "moderation_blocklist")
+	if err != nil {
+		return nil, err
+	}
+	return &BlocklistModerator{
+		hashes: lo.SliceToMap(hashes, func(h string) (string, struct{}) {
+			return strings.ToLower(strings.TrimSpace(h)), struct{}{}
+		}),
+	}, nil
+}
+
+func (m *BlocklistModerator) Moderate(ctx context.Context, _ string, img []byte) (Verdict, error) {
+	hash := Hash(img)
+	if _, ok := m.hashes[hash]; ok {
+		log.FromContextOrDiscard(ctx).WithGroup("blocklist").Info("image is blocklisted", "hash", hash)
+		return Verdict{Moderator: "blocklist", Reason: "image " + hash + " is blocklisted"}, nil
+	}
+	return Verdict{Allowed: true, Moderator: "blocklist"}, nil
+}
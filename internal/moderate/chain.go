@@ -0,0 +1,34 @@
+package moderate
+
+import (
+	"context"
+	"strings"
+)
+
+// ChainModerator asks each moderator in turn and rejects as soon as one of them does.
+type ChainModerator struct {
+	moderators []Moderator
+}
+
+func NewChainModerator(moderators ...Moderator) *ChainModerator {
+	return &ChainModerator{moderators}
+}
+
+func (m *ChainModerator) Moderate(ctx context.Context, date string, img []byte) (Verdict, error) {
+	allowed := Verdict{Allowed: true}
+	var names []string
+	for _, moderator := range m.moderators {
+		verdict, err := moderator.Moderate(ctx, date, img)
+		if err != nil || !verdict.Allowed {
+			return verdict, err
+		}
+		if verdict.Moderator != "" {
+			names = append(names, verdict.Moderator)
+		}
+		if verdict.Score != "" {
+			allowed.Score = verdict.Score
+		}
+	}
+	allowed.Moderator = strings.Join(names, ",")
+	return allowed, nil
+}
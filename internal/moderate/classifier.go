@@ -0,0 +1,74 @@
+package moderate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/do"
+)
+
+// ClassifierModerator posts each image to an NSFW classifier, such as a model served
+// next to kittenbot, which must answer with {"score": 0.0-1.0}. Images scoring at or
+// above the threshold are rejected.
+type ClassifierModerator struct {
+	client    *http.Client
+	url       string
+	threshold float64
+}
+
+func NewClassifierModerator(i *do.Injector) (Moderator, error) {
+	return &ClassifierModerator{
+		client:    &http.Client{},
+		url:       do.MustInvokeNamed[string](i, "moderation_url"),
+		threshold: do.MustInvokeNamed[float64](i, "moderation_threshold"),
+	}, nil
+}
+
+type classifierResponse struct {
+	Score *float64 `json:"score"`
+}
+
+func (m *ClassifierModerator) Moderate(ctx context.Context, _ string, img []byte) (Verdict, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("classifier").With("url", m.url)
+	log.Info("scoring image")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(img))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Add("Content-Type", "image/png")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("classifier: %s", resp.Status)
+	}
+	var out classifierResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return Verdict{}, fmt.Errorf("classifier: %w", err)
+	}
+	if out.Score == nil {
+		return Verdict{}, fmt.Errorf("classifier: response has no score")
+	}
+
+	score := *out.Score
+	log.Info("scored image", "score", score)
+	verdict := Verdict{
+		Allowed:   score < m.threshold,
+		Moderator: "classifier",
+		Score:     strconv.FormatFloat(score, 'f', 3, 64),
+	}
+	if !verdict.Allowed {
+		verdict.Reason = fmt.Sprintf("nsfw score %s is at or above %g", verdict.Score, m.threshold)
+	}
+	return verdict, nil
+}
@@ -0,0 +1,116 @@
+package moderate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/phash"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+)
+
+// hashConcurrency caps how many recent images are looked up at once.
+const hashConcurrency = 16
+
+// DedupModerator rejects images whose perceptual hash is within a Hamming distance of an
+// image published in the last few days. Images of the day being generated are left out, so
+// regenerating a day from its recorded seed is not rejected as a duplicate of itself.
+type DedupModerator struct {
+	reader   store.Reader
+	now      clock.Clock
+	calendar *day.Calendar
+	days     int
+	distance int
+}
+
+func NewDedupModerator(i *do.Injector) (Moderator, error) {
+	reader, err := do.Invoke[store.Reader](i)
+	if err != nil {
+		return nil, err
+	}
+	return &DedupModerator{
+		reader:   reader,
+		now:      do.MustInvoke[clock.Clock](i),
+		calendar: do.MustInvoke[*day.Calendar](i),
+		days:     do.MustInvokeNamed[int](i, "dedup_days"),
+		distance: do.MustInvokeNamed[int](i, "dedup_distance"),
+	}, nil
+}
+
+func (m *DedupModerator) Moderate(ctx context.Context, date string, img []byte) (Verdict, error) {
+	hash, err := phash.DHash(img)
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	recent, err := m.recent(ctx, date)
+	if err != nil {
+		return Verdict{}, err
+	}
+	for _, r := range recent {
+		if d := phash.Distance(hash, r.hash); r.ok && d <= m.distance {
+			log.FromContextOrDiscard(ctx).WithGroup("dedup").Info("image is a near duplicate", "of", r.name, "distance", d)
+			return Verdict{Moderator: "dedup", Reason: fmt.Sprintf("image is %d bits from %s", d, r.name)}, nil
+		}
+	}
+	return Verdict{Allowed: true, Moderator: "dedup"}, nil
+}
+
+type hashed struct {
+	name string
+	hash uint64
+	ok   bool
+}
+
+// recent hashes the images published in the last days, other than those of date. Images
+// stored before hashes were recorded in metadata are read and hashed; any that cannot be
+// decoded are skipped.
+func (m *DedupModerator) recent(ctx context.Context, date string) ([]hashed, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("dedup")
+
+	start := m.calendar.Key(m.now().Add(-time.Duration(m.days) * 24 * time.Hour))
+	names, err := m.reader.List(ctx, store.ListParams{StartAfter: start, Delimiter: "/"})
+	if err != nil {
+		return nil, err
+	}
+	names = lo.Filter(names, func(name string, _ int) bool {
+		key, _, ok := day.ParseName(name, ".png")
+		return ok && key != date
+	})
+
+	recent := make([]hashed, len(names))
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(hashConcurrency)
+	for n, name := range names {
+		n, name := n, name
+		group.Go(func() error {
+			recent[n].name = name
+			obj, err := m.reader.Stat(ctx, name)
+			if err != nil {
+				return err
+			}
+			if h, err := phash.Parse(obj.Metadata["dhash"]); err == nil {
+				recent[n].hash, recent[n].ok = h, true
+				return nil
+			}
+
+			data, err := m.reader.Read(ctx, name)
+			if err != nil {
+				return err
+			}
+			if recent[n].hash, err = phash.DHash(data); err != nil {
+				log.Warn("skipping image that cannot be hashed", "name", name, "error", err)
+				return nil
+			}
+			recent[n].ok = true
+			return nil
+		})
+	}
+	return recent, group.Wait()
+}
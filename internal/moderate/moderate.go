@@ -0,0 +1,32 @@
+package moderate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrRejected is returned when moderation rejected every image generated for a run.
+var ErrRejected = errors.New("image rejected by moderation")
+
+// Verdict is a moderator's decision about one image.
+type Verdict struct {
+	Allowed bool
+	// Moderator names what decided, empty when moderation is disabled.
+	Moderator string
+	// Score is the classifier's score, empty when the image was not scored.
+	Score  string
+	Reason string
+}
+
+// Moderator decides whether an image generated for the day date may be published.
+type Moderator interface {
+	Moderate(ctx context.Context, date string, img []byte) (Verdict, error)
+}
+
+// Hash returns the hex encoded SHA-256 of an image, as listed in a blocklist.
+func Hash(img []byte) string {
+	sum := sha256.Sum256(img)
+	return hex.EncodeToString(sum[:])
+}
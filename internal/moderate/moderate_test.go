@@ -0,0 +1,153 @@
+package moderate_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/moderate"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+)
+
+// classifier serves a stand-in NSFW classifier that scores each image by its first byte.
+func classifier(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		img, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "image/png" || len(img) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]float64{"score": float64(img[0]) / 100})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClassifierAndBlocklist(t *testing.T) {
+	srv := classifier(t)
+	blocked := []byte{10, 1}
+
+	i := do.New()
+	do.ProvideNamedValue[string](i, "moderation_url", srv.URL)
+	do.ProvideNamedValue[float64](i, "moderation_threshold", 0.8)
+	do.ProvideNamedValue[[]string](i, "moderation_blocklist", []string{moderate.Hash(blocked)})
+	c, err := moderate.NewClassifierModerator(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := moderate.NewBlocklistModerator(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := moderate.NewChainModerator(c, b)
+
+	tests := []struct {
+		name string
+		img  []byte
+		want moderate.Verdict
+	}{
+		{
+			name: "allowed",
+			img:  []byte{12},
+			want: moderate.Verdict{Allowed: true, Moderator: "classifier,blocklist", Score: "0.120"},
+		},
+		{
+			name: "scored nsfw",
+			img:  []byte{80},
+			want: moderate.Verdict{Moderator: "classifier", Score: "0.800", Reason: "nsfw score 0.800 is at or above 0.8"},
+		},
+		{
+			name: "blocklisted",
+			img:  blocked,
+			want: moderate.Verdict{Moderator: "blocklist", Reason: "image " + moderate.Hash(blocked) + " is blocklisted"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chain.Moderate(context.Background(), "20231201", tt.img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := chain.Moderate(context.Background(), "20231201", nil); err == nil {
+		t.Error("expected an error when the classifier fails")
+	}
+}
+
+// gradient encodes a small PNG whose brightness runs left to right, or right to left.
+func gradient(t *testing.T, reversed bool) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 32, 32))
+	for x := 0; x < 32; x++ {
+		v := uint8(x * 8)
+		if reversed {
+			v = 255 - v
+		}
+		for y := 0; y < 32; y++ {
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDedup(t *testing.T) {
+	now := func() time.Time { return time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC) }
+	s := fake.NewStore(now)
+	s.Put(store.UploadParams{Name: "20231130.png", Data: gradient(t, false)})
+	s.Put(store.UploadParams{Name: "20231201.png", Data: gradient(t, true)})
+
+	i := do.New()
+	do.ProvideValue[store.Reader](i, s)
+	do.ProvideValue[clock.Clock](i, now)
+	do.ProvideValue(i, day.NewCalendar(time.UTC))
+	do.ProvideNamedValue[int](i, "dedup_days", 3)
+	do.ProvideNamedValue[int](i, "dedup_distance", 4)
+	m, err := moderate.NewDedupModerator(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		date    string
+		img     []byte
+		allowed bool
+	}{
+		{name: "duplicate of yesterday", date: "20231201", img: gradient(t, false)},
+		{name: "regenerating the same day", date: "20231201", img: gradient(t, true), allowed: true},
+		{name: "duplicate of another day", date: "20231202", img: gradient(t, true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.Moderate(context.Background(), tt.date, tt.img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Allowed != tt.allowed {
+				t.Errorf("got %+v, want allowed %v", got, tt.allowed)
+			}
+		})
+	}
+}
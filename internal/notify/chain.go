@@ -0,0 +1,23 @@
+package notify
+
+import (
+	"context"
+	"errors"
+)
+
+// ChainNotifier sends every event to each notifier, carrying on past failures.
+type ChainNotifier struct {
+	notifiers []Notifier
+}
+
+func NewChainNotifier(notifiers ...Notifier) *ChainNotifier {
+	return &ChainNotifier{notifiers}
+}
+
+func (n *ChainNotifier) Notify(ctx context.Context, e Event) error {
+	errs := make([]error, 0, len(n.notifiers))
+	for _, notifier := range n.notifiers {
+		errs = append(errs, notifier.Notify(ctx, e))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,18 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/samber/do"
+)
+
+// NoopNotifier drops every event.
+type NoopNotifier struct{}
+
+func NewNoopNotifier(i *do.Injector) (Notifier, error) {
+	return &NoopNotifier{}, nil
+}
+
+func (n *NoopNotifier) Notify(context.Context, Event) error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timeout bounds how long sending a notification may take.
+const Timeout = 30 * time.Second
+
+// Event describes how a run ended. Action is run, approve or reject. Phase names the phase
+// that failed, empty when the run failed before any phase or succeeded. Chain lists the
+// error and every error it wraps, outermost first.
+type Event struct {
+	Action string    `json:"action"`
+	Failed bool      `json:"failed"`
+	Phase  string    `json:"phase,omitempty"`
+	Input  any       `json:"input"`
+	Error  string    `json:"error,omitempty"`
+	Chain  []string  `json:"chain,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// NewEvent describes a run that ended with err, which is nil when it succeeded.
+func NewEvent(action, phase string, input any, err error, now time.Time) Event {
+	e := Event{Action: action, Failed: err != nil, Phase: phase, Input: input, Time: now.UTC()}
+	if err != nil {
+		e.Error = err.Error()
+		e.Chain = chain(err)
+	}
+	return e
+}
+
+// Subject summarises the event in a line.
+func (e Event) Subject() string {
+	switch {
+	case !e.Failed:
+		return fmt.Sprintf("kittenbot %s succeeded", e.Action)
+	case e.Phase != "":
+		return fmt.Sprintf("kittenbot %s failed in the %s phase", e.Action, e.Phase)
+	default:
+		return fmt.Sprintf("kittenbot %s failed", e.Action)
+	}
+}
+
+// Text describes the event for people: the subject, the input and the error chain.
+func (e Event) Text() string {
+	var b strings.Builder
+	b.WriteString(e.Subject())
+	b.WriteString("\n\ninput: ")
+	input, _ := json.Marshal(e.Input)
+	b.Write(input)
+	b.WriteString("\n")
+	if e.Failed {
+		b.WriteString("error: ")
+		b.WriteString(e.Error)
+		b.WriteString("\n")
+		for _, msg := range e.Chain[1:] {
+			b.WriteString("  caused by: ")
+			b.WriteString(msg)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Notifier tells someone how a run ended.
+type Notifier interface {
+	Notify(context.Context, Event) error
+}
+
+// chain returns the messages of err and every error it wraps, depth first. A wrapped error
+// with the same message as its wrapper, such as a lone joined error, is left out.
+func chain(err error) []string {
+	var inner []error
+	switch x := err.(type) {
+	case interface{ Unwrap() error }:
+		inner = []error{x.Unwrap()}
+	case interface{ Unwrap() []error }:
+		inner = x.Unwrap()
+	}
+
+	msgs := []string{err.Error()}
+	for _, e := range inner {
+		if e == nil {
+			continue
+		}
+		if c := chain(e); c[0] == msgs[0] {
+			msgs = append(msgs, c[1:]...)
+		} else {
+			msgs = append(msgs, c...)
+		}
+	}
+	return msgs
+}
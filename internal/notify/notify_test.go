@@ -0,0 +1,283 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dmorgan81/kittenbot/internal/notify"
+	"github.com/samber/do"
+)
+
+var now = time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC)
+
+var errBoom = errors.New("boom")
+
+func event() notify.Event {
+	err := fmt.Errorf("image phase: %w", fmt.Errorf("generating: %w", errBoom))
+	return notify.NewEvent("run", "image", map[string]string{"date": "20231201"}, err, now)
+}
+
+func TestEvent(t *testing.T) {
+	e := event()
+	want := []string{"image phase: generating: boom", "generating: boom", "boom"}
+	if fmt.Sprint(e.Chain) != fmt.Sprint(want) {
+		t.Errorf("got chain %q, want %q", e.Chain, want)
+	}
+	if got := e.Subject(); got != "kittenbot run failed in the image phase" {
+		t.Errorf("got subject %q", got)
+	}
+	wantText := `kittenbot run failed in the image phase
+
+input: {"date":"20231201"}
+error: image phase: generating: boom
+  caused by: generating: boom
+  caused by: boom
+`
+	if got := e.Text(); got != wantText {
+		t.Errorf("got text:\n%s\nwant:\n%s", got, wantText)
+	}
+
+	joined := notify.NewEvent("approve", "", nil, errors.Join(errBoom), now)
+	if len(joined.Chain) != 1 {
+		t.Errorf("got chain %q, want the lone joined error left out", joined.Chain)
+	}
+
+	ok := notify.NewEvent("run", "", nil, nil, now)
+	if ok.Failed || ok.Chain != nil || ok.Subject() != "kittenbot run succeeded" {
+		t.Errorf("got %+v for a successful run", ok)
+	}
+}
+
+// webhook serves a stand-in webhook that records each JSON body posted to it.
+func webhook(t *testing.T, status int) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &bodies
+}
+
+func TestWebhooks(t *testing.T) {
+	ctx := context.Background()
+	e := event()
+
+	tests := []struct {
+		name  string
+		build func(*do.Injector) (notify.Notifier, error)
+		key   string
+		want  any
+	}{
+		{name: "webhook", build: notify.NewWebhookNotifier, key: "phase", want: "image"},
+		{name: "discord", build: notify.NewDiscordNotifier, key: "content", want: e.Text()},
+		{name: "slack", build: notify.NewSlackNotifier, key: "text", want: e.Text()},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			srv, bodies := webhook(t, http.StatusNoContent)
+			i := do.New()
+			do.ProvideNamedValue[string](i, "notify_webhook_url", srv.URL)
+			do.ProvideNamedValue[string](i, "notify_discord_webhook", srv.URL)
+			do.ProvideNamedValue[string](i, "notify_slack_webhook", srv.URL)
+			n, err := tt.build(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := n.Notify(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+			if len(*bodies) != 1 {
+				t.Fatalf("got %d requests, want 1", len(*bodies))
+			}
+			if got := (*bodies)[0][tt.key]; got != tt.want {
+				t.Errorf("got %s %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscordTruncatesRunes(t *testing.T) {
+	srv, bodies := webhook(t, http.StatusNoContent)
+	i := do.New()
+	do.ProvideNamedValue[string](i, "notify_discord_webhook", srv.URL)
+	n, err := notify.NewDiscordNotifier(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := notify.NewEvent("run", "", nil, errors.New(strings.Repeat("é", 3000)), now)
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	content := (*bodies)[0]["content"].(string)
+	if !utf8.ValidString(content) || utf8.RuneCountInString(content) != 2000 || !strings.HasSuffix(content, "é…") {
+		t.Errorf("got %d runes of content, valid UTF-8 %v", utf8.RuneCountInString(content), utf8.ValidString(content))
+	}
+}
+
+func TestChainCarriesOnPastFailures(t *testing.T) {
+	failing, _ := webhook(t, http.StatusInternalServerError)
+	working, bodies := webhook(t, http.StatusOK)
+
+	i := do.New()
+	do.ProvideNamedValue[string](i, "notify_webhook_url", failing.URL)
+	do.ProvideNamedValue[string](i, "notify_slack_webhook", working.URL)
+	w, _ := notify.NewWebhookNotifier(i)
+	s, _ := notify.NewSlackNotifier(i)
+
+	err := notify.NewChainNotifier(w, s).Notify(context.Background(), event())
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("got %v, want the failing webhook's status", err)
+	}
+	if len(*bodies) != 1 {
+		t.Errorf("got %d requests to the working webhook, want 1", len(*bodies))
+	}
+}
+
+// smtpServer serves just enough SMTP for net/smtp to send a message, which it returns on
+// the channel along with its recipients.
+func smtpServer(t *testing.T) (string, <-chan []string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+
+		var got []string
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "MAIL":
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				got = append(got, line)
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := io.ReadAll(bufio.NewReader(tp.DotReader()))
+				if err != nil {
+					return
+				}
+				got = append(got, string(data))
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				received <- got
+				return
+			default:
+				tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return l.Addr().String(), received
+}
+
+func TestSMTP(t *testing.T) {
+	addr, received := smtpServer(t)
+
+	i := do.New()
+	do.ProvideNamedValue[string](i, "notify_smtp_addr", addr)
+	do.ProvideNamedValue[string](i, "notify_smtp_from", "kittenbot@example.com")
+	do.ProvideNamedValue[[]string](i, "notify_smtp_to", []string{"ops@example.com", "dev@example.com"})
+	do.ProvideNamedValue[string](i, "notify_smtp_username", "")
+	n, err := notify.NewSMTPNotifier(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Notify(context.Background(), event()); err != nil {
+		t.Fatal(err)
+	}
+	got := <-received
+	if len(got) != 3 || !strings.Contains(got[0], "ops@example.com") || !strings.Contains(got[1], "dev@example.com") {
+		t.Fatalf("got %q, want a message to both recipients", got)
+	}
+	for _, want := range []string{
+		"Subject: kittenbot run failed in the image phase\n",
+		"To: ops@example.com, dev@example.com\n",
+		"  caused by: boom\n",
+	} {
+		if !strings.Contains(got[2], want) {
+			t.Errorf("message is missing %q:\n%s", want, got[2])
+		}
+	}
+}
+
+func TestSMTPGivesUpWithContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	// The server accepts the connection but never greets the client.
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	i := do.New()
+	do.ProvideNamedValue[string](i, "notify_smtp_addr", l.Addr().String())
+	do.ProvideNamedValue[string](i, "notify_smtp_from", "kittenbot@example.com")
+	do.ProvideNamedValue[[]string](i, "notify_smtp_to", []string{"ops@example.com"})
+	do.ProvideNamedValue[string](i, "notify_smtp_username", "")
+	n, err := notify.NewSMTPNotifier(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- n.Notify(ctx, event()) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected an error from a server that never answers")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notify did not give up when its context was done")
+	}
+}
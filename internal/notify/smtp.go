@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/do"
+)
+
+// SMTPNotifier emails events. It authenticates with PLAIN when a username is set, which
+// net/smtp only allows over TLS or to localhost.
+type SMTPNotifier struct {
+	addr string
+	from string
+	to   []string
+	auth smtp.Auth
+}
+
+func NewSMTPNotifier(i *do.Injector) (Notifier, error) {
+	n := &SMTPNotifier{
+		addr: do.MustInvokeNamed[string](i, "notify_smtp_addr"),
+		from: do.MustInvokeNamed[string](i, "notify_smtp_from"),
+		to:   do.MustInvokeNamed[[]string](i, "notify_smtp_to"),
+	}
+	if username := do.MustInvokeNamed[string](i, "notify_smtp_username"); username != "" {
+		password, err := do.InvokeNamed[string](i, "notify_smtp_password")
+		if err != nil {
+			return nil, err
+		}
+		host, _, err := net.SplitHostPort(n.addr)
+		if err != nil {
+			return nil, err
+		}
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n, nil
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, e Event) error {
+	log.FromContextOrDiscard(ctx).WithGroup("smtp").Info("sending notification", "subject", e.Subject(), "to", n.to)
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", e.Subject())
+	fmt.Fprintf(&msg, "Date: %s\r\n", e.Time.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(e.Text(), "\n", "\r\n"))
+
+	return n.send(ctx, []byte(msg.String()))
+}
+
+// send delivers msg as smtp.SendMail does, upgrading to TLS when the server offers it, but
+// gives up when ctx is done.
+func (n *SMTPNotifier) send(ctx context.Context, msg []byte) error {
+	host, _, err := net.SplitHostPort(n.addr)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server does not support AUTH")
+		}
+		if err := c.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
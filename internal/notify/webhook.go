@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/do"
+)
+
+// discordLimit is the most characters a Discord message may hold, counted in runes.
+const discordLimit = 2000
+
+// WebhookNotifier posts events as JSON. The generic webhook receives the Event itself;
+// Discord and Slack incoming webhooks receive its Text in the shape they expect.
+type WebhookNotifier struct {
+	client *http.Client
+	kind   string
+	url    string
+	body   func(Event) any
+}
+
+func NewWebhookNotifier(i *do.Injector) (Notifier, error) {
+	url := do.MustInvokeNamed[string](i, "notify_webhook_url")
+	return &WebhookNotifier{&http.Client{Timeout: Timeout}, "webhook", url, func(e Event) any { return e }}, nil
+}
+
+func NewDiscordNotifier(i *do.Injector) (Notifier, error) {
+	url, err := do.InvokeNamed[string](i, "notify_discord_webhook")
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookNotifier{&http.Client{Timeout: Timeout}, "discord", url, func(e Event) any {
+		text := []rune(e.Text())
+		if len(text) > discordLimit {
+			text = append(text[:discordLimit-1], '…')
+		}
+		return map[string]string{"content": string(text)}
+	}}, nil
+}
+
+func NewSlackNotifier(i *do.Injector) (Notifier, error) {
+	url, err := do.InvokeNamed[string](i, "notify_slack_webhook")
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookNotifier{&http.Client{Timeout: Timeout}, "slack", url, func(e Event) any {
+		return map[string]string{"text": e.Text()}
+	}}, nil
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, e Event) error {
+	log.FromContextOrDiscard(ctx).WithGroup(n.kind).Info("sending notification", "subject", e.Subject())
+
+	body, err := json.Marshal(n.body(e))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: %s", n.kind, resp.Status)
+	}
+	return nil
+}
@@ -3,39 +3,141 @@ package page
 import (
 	"bytes"
 	"context"
-	_ "embed"
+	"embed"
 	"html/template"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/dmorgan81/kittenbot/internal/index"
 	"github.com/dmorgan81/kittenbot/internal/log"
 	"github.com/samber/do"
 )
 
-//go:embed assets/latest.html
-var latestTmpl string
+//go:embed assets/theme
+var assets embed.FS
 
+// pages are the templates of a theme. Each is parsed together with base.html, which lays
+// out the site and leaves the "title", "head" and "content" blocks for the page to fill.
+var pages = []string{"day", "archive", "404"}
+
+// Site describes the site to every template.
+type Site struct {
+	URL         string
+	Title       string
+	Description string
+}
+
+// Params describes a day's page. Image, Model, Prompt and Seed are the pick of the day and
+// Metadata all of its metadata; Width and Height are its dimensions in pixels, 0 when not
+// known. Variants lists every image when the day has more than one. Previous and Next are the
+// published days either side, nil where there are none.
 type Params struct {
-	Image  string
-	Model  string
-	Prompt string
-	Seed   string
+	Site     Site
+	Date     time.Time
+	Image    string
+	Model    string
+	Prompt   string
+	Seed     string
+	Width    int
+	Height   int
+	Metadata map[string]string
+	Variants []Variant
+	Previous *index.Day
+	Next     *index.Day
+}
+
+type Variant struct {
+	Image    string
+	Model    string
+	Prompt   string
+	Seed     string
+	Metadata map[string]string
+}
+
+// ArchiveParams describes the archive page, listing every published day oldest first.
+type ArchiveParams struct {
+	Site Site
+	Days []index.Day
 }
 
+// NotFoundParams describes the 404 page.
+type NotFoundParams struct {
+	Site Site
+}
+
+// Templator renders pages from a theme: the embedded default, with any template overridden
+// by a file of the same name in theme_dir.
 type Templator struct {
-	tmpl *template.Template
+	site  Site
+	pages map[string]*template.Template
 }
 
 func NewTemplator(i *do.Injector) (*Templator, error) {
-	tmpl := template.Must(template.New("latest").Parse(latestTmpl))
-	return &Templator{tmpl}, nil
+	theme, err := fs.Sub(assets, "assets/theme")
+	if err != nil {
+		return nil, err
+	}
+	if dir := do.MustInvokeNamed[string](i, "theme_dir"); dir != "" {
+		theme = overlay{os.DirFS(dir), theme}
+	}
+
+	t := &Templator{
+		site: Site{
+			URL:         strings.TrimSuffix(do.MustInvokeNamed[string](i, "site_url"), "/"),
+			Title:       do.MustInvokeNamed[string](i, "site_title"),
+			Description: do.MustInvokeNamed[string](i, "site_description"),
+		},
+		pages: make(map[string]*template.Template, len(pages)),
+	}
+	for _, page := range pages {
+		tmpl, err := template.ParseFS(theme, "base.html", page+".html")
+		if err != nil {
+			return nil, err
+		}
+		t.pages[page] = tmpl
+	}
+	return t, nil
+}
+
+// Day renders a day's page.
+func (t *Templator) Day(ctx context.Context, params Params) ([]byte, error) {
+	params.Site = t.site
+	return t.execute(ctx, "day", params)
+}
+
+// Archive renders the archive page.
+func (t *Templator) Archive(ctx context.Context, days []index.Day) ([]byte, error) {
+	return t.execute(ctx, "archive", ArchiveParams{Site: t.site, Days: days})
 }
 
-func (g *Templator) Template(ctx context.Context, params Params) ([]byte, error) {
-	log := log.FromContextOrDiscard(ctx).WithGroup("templator").With("params", params)
+// NotFound renders the 404 page.
+func (t *Templator) NotFound(ctx context.Context) ([]byte, error) {
+	return t.execute(ctx, "404", NotFoundParams{Site: t.site})
+}
+
+func (t *Templator) execute(ctx context.Context, page string, params any) ([]byte, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("templator").With("page", page)
 	log.Info("generating page")
 
 	var data bytes.Buffer
-	if err := g.tmpl.Execute(&data, params); err != nil {
+	if err := t.pages[page].ExecuteTemplate(&data, "base.html", params); err != nil {
 		return nil, err
 	}
 	return data.Bytes(), nil
 }
+
+// overlay opens files from the first file system that has them.
+type overlay []fs.FS
+
+func (o overlay) Open(name string) (fs.File, error) {
+	var err error
+	for _, fsys := range o {
+		var f fs.File
+		if f, err = fsys.Open(name); err == nil {
+			return f, nil
+		}
+	}
+	return nil, err
+}
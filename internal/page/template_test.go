@@ -0,0 +1,92 @@
+package page_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/index"
+	"github.com/dmorgan81/kittenbot/internal/page"
+	"github.com/samber/do"
+)
+
+func templator(t *testing.T, themeDir string) *page.Templator {
+	t.Helper()
+	i := do.New()
+	do.ProvideNamedValue[string](i, "site_url", "https://kittenbot.io/")
+	do.ProvideNamedValue[string](i, "site_title", "KittenBot")
+	do.ProvideNamedValue[string](i, "site_description", "Daily AI Generated Kittens")
+	do.ProvideNamedValue[string](i, "theme_dir", themeDir)
+	templator, err := page.NewTemplator(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return templator
+}
+
+func TestDay(t *testing.T) {
+	previous := &index.Day{Key: "20231130"}
+	html, err := templator(t, "").Day(context.Background(), page.Params{
+		Date:     time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+		Image:    "20231201.png",
+		Model:    "icbinp",
+		Prompt:   `cute "kitten" </script>`,
+		Seed:     "42",
+		Width:    512,
+		Height:   768,
+		Previous: previous,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"<title>KittenBot - Daily AI Generated Kittens</title>",
+		`<img src="20231201.png" alt="cute &#34;kitten&#34; &lt;/script&gt;:icbinp:42" width="512" height="768">`,
+		`<meta property="og:image" content="https://kittenbot.io/20231201.png">`,
+		`<meta property="og:image:width" content="512">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`"contentUrl": "https://kittenbot.io/20231201.png",`,
+		`"width":  512 ,`,
+		`"caption": "cute \"kitten\" \u003c/script\u003e",`,
+		`<a href="https://kittenbot.io/20231130.html" rel="prev">`,
+		`<time datetime="2023-12-01">Friday, 1 December 2023</time>`,
+		`<a href="https://kittenbot.io/archive.html">archive</a>`,
+	} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("page is missing %s:\n%s", want, html)
+		}
+	}
+	if strings.Contains(string(html), `rel="next"`) {
+		t.Errorf("page links to a next day that does not exist:\n%s", html)
+	}
+}
+
+func TestThemeDir(t *testing.T) {
+	dir := t.TempDir()
+	day := `{{ define "content" }}<h1>{{ .Site.Title }} {{ index .Metadata "model" }}</h1>{{ end }}`
+	if err := os.WriteFile(filepath.Join(dir, "day.html"), []byte(day), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	templator := templator(t, dir)
+
+	html, err := templator.Day(context.Background(), page.Params{Metadata: map[string]string{"model": "icbinp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "<h1>KittenBot icbinp</h1>") {
+		t.Errorf("overridden day.html was not used:\n%s", html)
+	}
+
+	// Templates missing from the directory fall back to the built-in theme.
+	html, err = templator.NotFound(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), `<a href="https://kittenbot.io/">`) {
+		t.Errorf("built-in 404.html was not used:\n%s", html)
+	}
+}
@@ -2,6 +2,9 @@ package param
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
@@ -12,11 +15,19 @@ import (
 )
 
 type ParameterStoreFetcher struct {
-	client *ssm.Client
+	client    *ssm.Client
+	recursive bool
 }
 
 func NewParameterStoreFetcher(i *do.Injector) (Fetcher, error) {
-	return &ParameterStoreFetcher{client: do.MustInvoke[*ssm.Client](i)}, nil
+	client, err := do.Invoke[*ssm.Client](i)
+	if err != nil {
+		return nil, err
+	}
+	return &ParameterStoreFetcher{
+		client:    client,
+		recursive: do.MustInvokeNamed[bool](i, "param_recursive"),
+	}, nil
 }
 
 func (f *ParameterStoreFetcher) Fetch(ctx context.Context, path string) (string, error) {
@@ -27,24 +38,43 @@ func (f *ParameterStoreFetcher) Fetch(ctx context.Context, path string) (string,
 		Name:           aws.String(path),
 		WithDecryption: aws.Bool(true),
 	})
-	if err != nil {
+	var nf *types.ParameterNotFound
+	if errors.As(err, &nf) {
+		return "", fmt.Errorf("%s: %w", path, ErrNotFound)
+	} else if err != nil {
 		return "", err
 	}
 	return aws.ToString(out.Parameter.Value), nil
 }
 
-func (f *ParameterStoreFetcher) FetchAll(ctx context.Context, path string) ([]string, error) {
-	log := log.FromContextOrDiscard(ctx).WithGroup("parameter store").With("path", path)
+// FetchAll follows every page of results and orders them by parameter name, so the
+// result doesn't depend on how Parameter Store happens to return them. A path with no
+// parameters under it is not found.
+func (f *ParameterStoreFetcher) FetchAll(ctx context.Context, path string) ([]Param, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("parameter store").With("path", path, "recursive", f.recursive)
 	log.Info("fetching all parameters")
 
-	out, err := f.client.GetParametersByPath(ctx, &ssm.GetParametersByPathInput{
+	var params []Param
+	pager := ssm.NewGetParametersByPathPaginator(f.client, &ssm.GetParametersByPathInput{
 		Path:           aws.String(path),
+		Recursive:      aws.Bool(f.recursive),
 		WithDecryption: aws.Bool(true),
 	})
-	if err != nil {
-		return nil, err
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, lo.Map(page.Parameters, func(p types.Parameter, _ int) Param {
+			return Param{Name: aws.ToString(p.Name), Value: aws.ToString(p.Value)}
+		})...)
 	}
-	return lo.Map(out.Parameters, func(p types.Parameter, _ int) string {
-		return aws.ToString(p.Value)
-	}), nil
+
+	if len(params) == 0 {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+	return params, nil
 }
@@ -0,0 +1,101 @@
+package param
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// awsStub serves a stand-in for an AWS JSON API. respond is called with the operation named
+// by the request's X-Amz-Target and its decoded body, and returns the status and body to
+// answer with.
+func awsStub(t *testing.T, respond func(op string, body map[string]any) (int, any)) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, op, _ := strings.Cut(r.Header.Get("X-Amz-Target"), ".")
+		status, out := respond(op, body)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// credentials signs requests to awsStub, which does not check them.
+var credentials = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+	return aws.Credentials{AccessKeyID: "id", SecretAccessKey: "secret"}, nil
+})
+
+func TestParameterStoreFetchAll(t *testing.T) {
+	pages := map[string]map[string]any{
+		"": {
+			"Parameters": []map[string]string{{"Name": "/prompts/c", "Value": "3"}, {"Name": "/prompts/a", "Value": "1"}},
+			"NextToken":  "page2",
+		},
+		"page2": {
+			"Parameters": []map[string]string{{"Name": "/prompts/b", "Value": "2"}},
+		},
+	}
+	var requests []map[string]any
+	url := awsStub(t, func(op string, body map[string]any) (int, any) {
+		requests = append(requests, body)
+		token, _ := body["NextToken"].(string)
+		if op != "GetParametersByPath" || body["Path"] != "/prompts" || pages[token] == nil {
+			return http.StatusBadRequest, map[string]string{"__type": "ValidationException"}
+		}
+		return http.StatusOK, pages[token]
+	})
+	f := &ParameterStoreFetcher{
+		client: ssm.New(ssm.Options{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(url),
+			Credentials:  credentials,
+		}),
+		recursive: true,
+	}
+
+	params, err := f.FetchAll(context.Background(), "/prompts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(Values(params), ","); got != "1,2,3" {
+		t.Errorf("got values %s, want every page ordered by name", got)
+	}
+	if len(requests) != 2 || requests[0]["Recursive"] != true || requests[0]["WithDecryption"] != true {
+		t.Errorf("got requests %v", requests)
+	}
+}
+
+func TestParameterStoreNotFound(t *testing.T) {
+	url := awsStub(t, func(op string, body map[string]any) (int, any) {
+		if op == "GetParametersByPath" {
+			return http.StatusOK, map[string]any{"Parameters": []any{}}
+		}
+		return http.StatusBadRequest, map[string]string{"__type": "ParameterNotFound", "message": "no such parameter"}
+	})
+	f := &ParameterStoreFetcher{client: ssm.New(ssm.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(url),
+		Credentials:  credentials,
+	})}
+
+	if _, err := f.Fetch(context.Background(), "/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Fetch got %v, want ErrNotFound", err)
+	}
+	if _, err := f.FetchAll(context.Background(), "/empty"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FetchAll got %v, want ErrNotFound", err)
+	}
+}
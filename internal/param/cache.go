@@ -0,0 +1,43 @@
+package param
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+// cache holds values for a fixed time. It lives as long as the fetcher that owns it, which
+// in Lambda means across warm invocations of the same instance.
+type cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+	now     func() time.Time
+}
+
+func newCache(ttl time.Duration) *cache {
+	return &cache{ttl: ttl, entries: make(map[string]cacheEntry), now: time.Now}
+}
+
+func (c *cache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || c.now().After(e.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return e.value, true
+}
+
+func (c *cache) set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = cacheEntry{value, c.now().Add(c.ttl)}
+}
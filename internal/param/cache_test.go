@@ -0,0 +1,88 @@
+package param
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+func TestCacheExpires(t *testing.T) {
+	now := time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC)
+	c := newCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.set("key", "value")
+	now = now.Add(time.Minute)
+	if v, ok := c.get("key"); !ok || v != "value" {
+		t.Errorf("got %q, %v at the ttl, want the cached value", v, ok)
+	}
+	now = now.Add(time.Nanosecond)
+	if _, ok := c.get("key"); ok {
+		t.Error("got a value past the ttl")
+	}
+	if _, ok := c.entries["key"]; ok {
+		t.Error("expired entry was not evicted")
+	}
+}
+
+// TestSecretsManagerBatches fetches every key of a secret with one call until the cached
+// secret expires.
+func TestSecretsManagerBatches(t *testing.T) {
+	calls := 0
+	url := awsStub(t, func(op string, body map[string]any) (int, any) {
+		if op != "GetSecretValue" || body["SecretId"] != "kittenbot" {
+			return http.StatusBadRequest, map[string]string{"__type": "ResourceNotFoundException", "message": "no such secret"}
+		}
+		calls++
+		return http.StatusOK, map[string]string{"SecretString": `{"dezgo_key":"abc","reddit_client_id":42}`}
+	})
+
+	now := time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC)
+	f := &SecretsManagerFetcher{
+		client: secretsmanager.New(secretsmanager.Options{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(url),
+			Credentials:  credentials,
+		}),
+		cache: newCache(5 * time.Minute),
+	}
+	f.cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if v, err := f.Fetch(ctx, "kittenbot#dezgo_key"); err != nil || v != "abc" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	if v, err := f.Fetch(ctx, "kittenbot#reddit_client_id"); err != nil || v != "42" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	params, err := f.FetchAll(ctx, "kittenbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != 2 || params[0].Name != "kittenbot#dezgo_key" || params[1].Name != "kittenbot#reddit_client_id" {
+		t.Errorf("got params %+v", params)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want every key served by one", calls)
+	}
+
+	now = now.Add(5*time.Minute + time.Second)
+	if _, err := f.Fetch(ctx, "kittenbot#dezgo_key"); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want the secret fetched again once it expired", calls)
+	}
+
+	if _, err := f.Fetch(ctx, "kittenbot#missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for a missing key, want ErrNotFound", err)
+	}
+	if _, err := f.Fetch(ctx, "other#key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for a missing secret, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,41 @@
+package param
+
+import (
+	"context"
+	"errors"
+)
+
+// ChainFetcher tries each fetcher in order and returns the first value found. Only a
+// fetcher that does not have the parameter passes it on to the next; any other error, such
+// as a source that cannot be reached, is returned rather than hidden by a later source.
+type ChainFetcher struct {
+	fetchers []Fetcher
+}
+
+func NewChainFetcher(fetchers ...Fetcher) Fetcher {
+	return &ChainFetcher{fetchers}
+}
+
+func (f *ChainFetcher) Fetch(ctx context.Context, path string) (string, error) {
+	var errs []error
+	for _, fetcher := range f.fetchers {
+		v, err := fetcher.Fetch(ctx, path)
+		if !errors.Is(err, ErrNotFound) {
+			return v, err
+		}
+		errs = append(errs, err)
+	}
+	return "", errors.Join(errs...)
+}
+
+func (f *ChainFetcher) FetchAll(ctx context.Context, path string) ([]Param, error) {
+	var errs []error
+	for _, fetcher := range f.fetchers {
+		v, err := fetcher.FetchAll(ctx, path)
+		if !errors.Is(err, ErrNotFound) {
+			return v, err
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
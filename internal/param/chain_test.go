@@ -0,0 +1,100 @@
+package param
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// stub holds its parameters in a map and fails every fetch with err when it is set.
+type stub struct {
+	vars  map[string]string
+	err   error
+	calls int
+}
+
+func (s *stub) Fetch(_ context.Context, path string) (string, error) {
+	s.calls++
+	if s.err != nil {
+		return "", s.err
+	}
+	v, ok := s.vars[path]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func (s *stub) FetchAll(_ context.Context, path string) ([]Param, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	v, ok := s.vars[path]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return []Param{{Name: path, Value: v}}, nil
+}
+
+func TestChainFetcher(t *testing.T) {
+	errDown := errors.New("source is down")
+
+	tests := []struct {
+		name    string
+		first   *stub
+		second  *stub
+		want    string
+		wantErr error
+		calls   [2]int
+	}{
+		{
+			name:   "first source wins",
+			first:  &stub{vars: map[string]string{"/key": "first"}},
+			second: &stub{vars: map[string]string{"/key": "second"}},
+			want:   "first",
+			calls:  [2]int{1, 0},
+		},
+		{
+			name:   "falls through when not found",
+			first:  &stub{},
+			second: &stub{vars: map[string]string{"/key": "second"}},
+			want:   "second",
+			calls:  [2]int{1, 1},
+		},
+		{
+			name:    "stops at other errors",
+			first:   &stub{err: errDown},
+			second:  &stub{vars: map[string]string{"/key": "second"}},
+			wantErr: errDown,
+			calls:   [2]int{1, 0},
+		},
+		{
+			name:    "not found anywhere",
+			first:   &stub{},
+			second:  &stub{},
+			wantErr: ErrNotFound,
+			calls:   [2]int{1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := NewChainFetcher(tt.first, tt.second)
+
+			v, err := chain.Fetch(context.Background(), "/key")
+			if v != tt.want || (tt.wantErr == nil) != (err == nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("Fetch got %q, %v, want %q, %v", v, err, tt.want, tt.wantErr)
+			}
+			params, err := chain.FetchAll(context.Background(), "/key")
+			if got := Values(params); (tt.want == "") != (len(got) == 0) || (len(got) > 0 && got[0] != tt.want) {
+				t.Errorf("FetchAll got %q, want %q", got, tt.want)
+			}
+			if (tt.wantErr == nil) != (err == nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("FetchAll got %v, want %v", err, tt.wantErr)
+			}
+			if calls := [2]int{tt.first.calls, tt.second.calls}; calls != [2]int{2 * tt.calls[0], 2 * tt.calls[1]} {
+				t.Errorf("got calls %v, want %v for each of Fetch and FetchAll", calls, tt.calls)
+			}
+		})
+	}
+}
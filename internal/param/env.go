@@ -0,0 +1,106 @@
+package param
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/lo"
+)
+
+// EnvFetcher resolves parameter paths against environment-style variables. A path like
+// /kittenbot/reddit/client_id is looked up as KITTENBOT_REDDIT_CLIENT_ID, and FetchAll
+// returns every variable under the path's prefix, ordered by name.
+type EnvFetcher struct {
+	source string
+	vars   map[string]string
+}
+
+func NewEnvFetcher() (Fetcher, error) {
+	vars := make(map[string]string)
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		vars[k] = v
+	}
+	return &EnvFetcher{"environment", vars}, nil
+}
+
+func NewDotenvFetcher(path string) (Fetcher, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := parseDotenv(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &EnvFetcher{path, vars}, nil
+}
+
+func (f *EnvFetcher) Fetch(ctx context.Context, path string) (string, error) {
+	name := envName(path)
+	log := log.FromContextOrDiscard(ctx).WithGroup("env").With("path", path, "name", name, "source", f.source)
+	log.Info("fetching single parameter")
+
+	v, ok := f.vars[name]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return v, nil
+}
+
+func (f *EnvFetcher) FetchAll(ctx context.Context, path string) ([]Param, error) {
+	prefix := envName(path) + "_"
+	log := log.FromContextOrDiscard(ctx).WithGroup("env").With("path", path, "prefix", prefix, "source", f.source)
+	log.Info("fetching all parameters")
+
+	names := lo.Filter(lo.Keys(f.vars), func(k string, _ int) bool {
+		return strings.HasPrefix(k, prefix)
+	})
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s*: %w", prefix, ErrNotFound)
+	}
+	sort.Strings(names)
+	return lo.Map(names, func(k string, _ int) Param {
+		return Param{Name: k, Value: f.vars[k]}
+	}), nil
+}
+
+func envName(path string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(strings.Trim(path, "/")))
+}
+
+// parseDotenv understands KEY=VALUE lines, an optional leading export, # comments and
+// single or double quoted values.
+func parseDotenv(data string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n+1)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		} else if i := strings.Index(v, " #"); i >= 0 {
+			v = strings.TrimSpace(v[:i])
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
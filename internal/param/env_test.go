@@ -0,0 +1,86 @@
+package param
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "plain",
+			data: "KITTENBOT_DEZGO_KEY=abc\nKITTENBOT_SUBREDDIT = kittens \n",
+			want: map[string]string{"KITTENBOT_DEZGO_KEY": "abc", "KITTENBOT_SUBREDDIT": "kittens"},
+		},
+		{
+			name: "comments and blank lines",
+			data: "# reddit\n\n  # indented\nKEY=value # trailing\nHASH=a#b\n",
+			want: map[string]string{"KEY": "value", "HASH": "a#b"},
+		},
+		{
+			name: "quotes",
+			data: "DOUBLE=\"two words # kept\"\nSINGLE='it''s'\nEMPTY=\"\"\nUNMATCHED=\"open\n",
+			want: map[string]string{"DOUBLE": "two words # kept", "SINGLE": "it''s", "EMPTY": "", "UNMATCHED": "\"open"},
+		},
+		{
+			name: "export",
+			data: "export KEY=value\nexport  SPACED='quoted'\n",
+			want: map[string]string{"KEY": "value", "SPACED": "quoted"},
+		},
+		{
+			name: "value with equals",
+			data: "URL=https://example.com/?a=b\n",
+			want: map[string]string{"URL": "https://example.com/?a=b"},
+		},
+		{
+			name:    "missing equals",
+			data:    "KEY=value\nNOT A VARIABLE\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDotenv(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("got %s=%q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestEnvFetcher(t *testing.T) {
+	ctx := context.Background()
+	f := &EnvFetcher{"test", map[string]string{
+		"KITTENBOT_REDDIT_CLIENT_ID": "id",
+		"KITTENBOT_PROMPTS_2":        "b",
+		"KITTENBOT_PROMPTS_1":        "a",
+	}}
+
+	if v, err := f.Fetch(ctx, "/kittenbot/reddit/client-id"); err != nil || v != "id" {
+		t.Errorf("got %q, %v", v, err)
+	}
+	if _, err := f.Fetch(ctx, "/kittenbot/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	params, err := f.FetchAll(ctx, "/kittenbot/prompts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != 2 || params[0].Value != "a" || params[1].Value != "b" {
+		t.Errorf("got %+v", params)
+	}
+}
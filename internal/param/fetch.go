@@ -1,8 +1,27 @@
 package param
 
-import "context"
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/lo"
+)
+
+var ErrNotFound = errors.New("parameter not found")
+
+// Param is a single value returned by FetchAll along with the name it was found under.
+type Param struct {
+	Name  string
+	Value string
+}
 
 type Fetcher interface {
 	Fetch(context.Context, string) (string, error)
-	FetchAll(context.Context, string) ([]string, error)
+	FetchAll(context.Context, string) ([]Param, error)
+}
+
+func Values(params []Param) []string {
+	return lo.Map(params, func(p Param, _ int) string {
+		return p.Value
+	})
 }
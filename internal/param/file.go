@@ -0,0 +1,116 @@
+package param
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// FileFetcher resolves parameter paths against a YAML or JSON document, using each path
+// segment as a map key. FetchAll returns a list's items in order or a map's values ordered
+// by key. Values must be strings, numbers or booleans.
+type FileFetcher struct {
+	path string
+	root any
+}
+
+func NewFileFetcher(path string) (Fetcher, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is a subset of YAML so one decoder covers both.
+	var root any
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &FileFetcher{path, root}, nil
+}
+
+func (f *FileFetcher) Fetch(ctx context.Context, path string) (string, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("file").With("path", path, "file", f.path)
+	log.Info("fetching single parameter")
+
+	node, err := f.lookup(path)
+	if err != nil {
+		return "", err
+	}
+	return scalar(path, node)
+}
+
+func (f *FileFetcher) FetchAll(ctx context.Context, path string) ([]Param, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("file").With("path", path, "file", f.path)
+	log.Info("fetching all parameters")
+
+	node, err := f.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	var params []Param
+	switch n := node.(type) {
+	case []any:
+		for idx, v := range n {
+			name := fmt.Sprintf("%s/%d", strings.TrimSuffix(path, "/"), idx)
+			value, err := scalar(name, v)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, Param{Name: name, Value: value})
+		}
+	case map[string]any:
+		keys := lo.Keys(n)
+		sort.Strings(keys)
+		for _, k := range keys {
+			name := strings.TrimSuffix(path, "/") + "/" + k
+			value, err := scalar(name, n[k])
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, Param{Name: name, Value: value})
+		}
+	default:
+		return nil, fmt.Errorf("%s: not a list or map", path)
+	}
+	if len(params) == 0 {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	return params, nil
+}
+
+// scalar renders a string, number or boolean as a parameter value.
+func scalar(path string, node any) (string, error) {
+	switch v := node.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "", fmt.Errorf("%s: no value", path)
+	case map[string]any, map[any]any, []any:
+		return "", fmt.Errorf("%s: not a single value", path)
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func (f *FileFetcher) lookup(path string) (any, error) {
+	node := f.root
+	for _, seg := range strings.Split(strings.Trim(path, "/"), "/") {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+		}
+		if node, ok = m[seg]; !ok {
+			return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+		}
+	}
+	return node, nil
+}
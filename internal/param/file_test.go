@@ -0,0 +1,80 @@
+package param
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fileParams = `
+reddit:
+  client_id: abc
+  retries: 3
+  ratio: 0.5
+  budget: 1000000.0
+  enabled: true
+  missing: null
+  nested:
+    key: value
+prompts:
+  - cute kitten
+  - 42
+bad_prompts:
+  - cute kitten
+  - text: sleepy kitten
+empty_list: []
+empty_map: {}
+`
+
+func TestFileFetcher(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "params.yaml")
+	if err := os.WriteFile(name, []byte(fileParams), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFileFetcher(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for path, want := range map[string]string{
+		"/reddit/client_id": "abc",
+		"/reddit/retries":   "3",
+		"/reddit/ratio":     "0.5",
+		"/reddit/budget":    "1000000",
+		"/reddit/enabled":   "true",
+	} {
+		if v, err := f.Fetch(ctx, path); err != nil || v != want {
+			t.Errorf("%s: got %q, %v, want %q", path, v, err, want)
+		}
+	}
+	for _, path := range []string{"/reddit/missing", "/reddit/nested", "/prompts"} {
+		if _, err := f.Fetch(ctx, path); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: got %v, want an error naming the path", path, err)
+		}
+	}
+	if _, err := f.Fetch(ctx, "/reddit/secret"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+
+	params, err := f.FetchAll(ctx, "/prompts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != 2 || params[0] != (Param{"/prompts/0", "cute kitten"}) || params[1] != (Param{"/prompts/1", "42"}) {
+		t.Errorf("got params %+v", params)
+	}
+	for path, want := range map[string]string{"/reddit": "/reddit/missing", "/bad_prompts": "/bad_prompts/1"} {
+		if _, err := f.FetchAll(ctx, path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want an error naming %s", path, err, want)
+		}
+	}
+	for _, path := range []string{"/empty_list", "/empty_map"} {
+		if _, err := f.FetchAll(ctx, path); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: got %v, want ErrNotFound", path, err)
+		}
+	}
+}
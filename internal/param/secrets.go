@@ -0,0 +1,132 @@
+package param
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+	"golang.org/x/sync/singleflight"
+)
+
+// SecretsManagerFetcher reads JSON secrets holding several keys. A path of secret-id#key
+// fetches one key and FetchAll(secret-id) returns every key; a path without a key fetches
+// the raw secret. Secrets are cached for ttl so one secret serves all of its keys with a
+// single call. Point AWS_ENDPOINT_URL_SECRETS_MANAGER at a stand-in to run without AWS.
+type SecretsManagerFetcher struct {
+	client *secretsmanager.Client
+	cache  *cache
+	group  singleflight.Group
+}
+
+func NewSecretsManagerFetcher(i *do.Injector) (Fetcher, error) {
+	client, err := do.Invoke[*secretsmanager.Client](i)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretsManagerFetcher{
+		client: client,
+		cache:  newCache(do.MustInvokeNamed[time.Duration](i, "secrets_ttl")),
+	}, nil
+}
+
+func (f *SecretsManagerFetcher) Fetch(ctx context.Context, path string) (string, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("secrets manager").With("path", path)
+	log.Info("fetching single parameter")
+
+	id, key, hasKey := strings.Cut(path, "#")
+	secret, err := f.secret(ctx, id)
+	if err != nil || !hasKey {
+		return secret, err
+	}
+
+	values, err := decodeSecret(id, secret)
+	if err != nil {
+		return "", err
+	}
+	v, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	return v, nil
+}
+
+func (f *SecretsManagerFetcher) FetchAll(ctx context.Context, path string) ([]Param, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("secrets manager").With("path", path)
+	log.Info("fetching all parameters")
+
+	secret, err := f.secret(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	values, err := decodeSecret(path, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := lo.Keys(values)
+	sort.Strings(keys)
+	return lo.Map(keys, func(k string, _ int) Param {
+		return Param{Name: path + "#" + k, Value: values[k]}
+	}), nil
+}
+
+func (f *SecretsManagerFetcher) secret(ctx context.Context, id string) (string, error) {
+	if v, ok := f.cache.get(id); ok {
+		return v, nil
+	}
+
+	v, err, _ := f.group.Do(id, func() (any, error) {
+		out, err := f.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(id),
+		})
+		if err != nil {
+			var nf *types.ResourceNotFoundException
+			if errors.As(err, &nf) {
+				return "", fmt.Errorf("%s: %w", id, ErrNotFound)
+			}
+			return "", err
+		}
+
+		secret := aws.ToString(out.SecretString)
+		f.cache.set(id, secret)
+		return secret, nil
+	})
+	return v.(string), err
+}
+
+// decodeSecret accepts strings, numbers and booleans as values so numeric keys don't need
+// quoting. Numbers keep the digits they were written with.
+func decodeSecret(id, secret string) (map[string]string, error) {
+	var raw map[string]any
+	decoder := json.NewDecoder(strings.NewReader(secret))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%s: secret is not a JSON object: %w", id, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[key] = v
+		case json.Number:
+			values[key] = v.String()
+		case bool:
+			values[key] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("%s: %s is not a string, number or boolean", id, key)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,28 @@
+package param
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeSecret(t *testing.T) {
+	values, err := decodeSecret("kittenbot", `{"key":"abc","id":1000000,"ratio":0.5,"big":12345678901234567890,"on":true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"key": "abc", "id": "1000000", "ratio": "0.5", "big": "12345678901234567890", "on": "true"}
+	for key, v := range want {
+		if values[key] != v {
+			t.Errorf("%s: got %q, want %q", key, values[key], v)
+		}
+	}
+
+	for _, secret := range []string{`{"nested":{"a":1}}`, `{"nested":[1,2]}`, `{"nested":null}`} {
+		if _, err := decodeSecret("kittenbot", secret); err == nil || !strings.Contains(err.Error(), "nested") {
+			t.Errorf("%s: got %v, want an error naming the key", secret, err)
+		}
+	}
+	if _, err := decodeSecret("kittenbot", `["abc"]`); err == nil {
+		t.Error("got no error for a secret that is not an object")
+	}
+}
@@ -0,0 +1,80 @@
+package phash
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"strconv"
+)
+
+// DHash returns the difference hash of an encoded image: it is shrunk to 9x8 grey pixels
+// and each bit records whether a pixel is darker than its right-hand neighbour. Similar
+// images have hashes a small Hamming distance apart.
+func DHash(data []byte) (uint64, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+
+	var grey [8][9]uint32
+	b := img.Bounds()
+	for y := 0; y < 8; y++ {
+		y0, y1 := span(b.Min.Y, b.Dy(), y, 8)
+		for x := 0; x < 9; x++ {
+			x0, x1 := span(b.Min.X, b.Dx(), x, 9)
+			// A cell of a large image holds enough 16-bit pixels to overflow 32 bits.
+			var sum, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sum += uint64(color.Gray16Model.Convert(img.At(sx, sy)).(color.Gray16).Y)
+					n++
+				}
+			}
+			grey[y][x] = uint32(sum / n)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if grey[y][x] < grey[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// span returns the source pixels covered by cell i of n along a side of the given length,
+// always at least one.
+func span(min, length, i, n int) (int, int) {
+	start := min + i*length/n
+	end := min + (i+1)*length/n
+	if end <= start {
+		end = start + 1
+	}
+	if end > min+length {
+		start, end = min+length-1, min+length
+	}
+	return start, end
+}
+
+// Distance returns the number of bits that differ between two hashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Format returns a hash as 16 hex digits, as stored in metadata.
+func Format(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// Parse is the inverse of Format.
+func Parse(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
@@ -0,0 +1,74 @@
+package phash
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// encode renders a 64x64 PNG whose grey level at each pixel is f(x, y).
+func encode(t *testing.T, f func(x, y int) uint8) []byte {
+	t.Helper()
+	return encodeSize(t, 64, f)
+}
+
+// encodeSize renders a size by size PNG whose grey level at each pixel is f(x, y).
+func encodeSize(t *testing.T, size int, f func(x, y int) uint8) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetGray(x, y, color.Gray{f(x, y)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDHash(t *testing.T) {
+	wave := func(x, y int) uint8 { return uint8((x*x + y*7) % 256) }
+	brighter := func(x, y int) uint8 { return wave(x, y)/2 + 100 }
+	mirrored := func(x, y int) uint8 { return wave(63-x, y) }
+
+	hash := func(f func(x, y int) uint8) uint64 {
+		h, err := DHash(encode(t, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	if d := Distance(hash(wave), hash(brighter)); d > 4 {
+		t.Errorf("brightened image is %d bits away, want at most 4", d)
+	}
+	if d := Distance(hash(wave), hash(mirrored)); d < 16 {
+		t.Errorf("mirrored image is only %d bits away, want at least 16", d)
+	}
+
+	if _, err := DHash([]byte("not an image")); err == nil {
+		t.Error("expected an error for data that is not an image")
+	}
+
+	h := hash(wave)
+	if got, err := Parse(Format(h)); err != nil || got != h {
+		t.Errorf("Parse(Format(%x)) = %x, %v", h, got, err)
+	}
+}
+
+// TestDHashLargeImage hashes an image whose cells hold more bright pixels than a 32-bit sum
+// can count.
+func TestDHashLargeImage(t *testing.T) {
+	const size = 2400
+	h, err := DHash(encodeSize(t, size, func(x, _ int) uint8 { return uint8(x * 255 / (size - 1)) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h != ^uint64(0) {
+		t.Errorf("got %016x for an image brightening left to right, want every bit set", h)
+	}
+}
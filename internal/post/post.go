@@ -4,6 +4,7 @@ import "context"
 
 type Params struct {
 	Date   string
+	Image  string
 	Model  string
 	Prompt string
 	Seed   string
@@ -3,6 +3,7 @@ package post
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/dmorgan81/kittenbot/internal/log"
 	"github.com/samber/do"
@@ -13,14 +14,22 @@ import (
 type RedditPoster struct {
 	client    *reddit.Client
 	subreddit string
+	site      string
 }
 
 func NewRedditPoster(i *do.Injector) (Poster, error) {
-	creds := reddit.Credentials{
-		ID:       do.MustInvokeNamed[string](i, "reddit_client_id"),
-		Secret:   do.MustInvokeNamed[string](i, "reddit_client_secret"),
-		Username: do.MustInvokeNamed[string](i, "reddit_username"),
-		Password: do.MustInvokeNamed[string](i, "reddit_password"),
+	var creds reddit.Credentials
+	for name, dst := range map[string]*string{
+		"reddit_client_id":     &creds.ID,
+		"reddit_client_secret": &creds.Secret,
+		"reddit_username":      &creds.Username,
+		"reddit_password":      &creds.Password,
+	} {
+		v, err := do.InvokeNamed[string](i, name)
+		if err != nil {
+			return nil, err
+		}
+		*dst = v
 	}
 	client, err := reddit.NewClient(creds)
 	if err != nil {
@@ -28,10 +37,12 @@ func NewRedditPoster(i *do.Injector) (Poster, error) {
 	}
 
 	subreddit := do.MustInvokeNamed[string](i, "subreddit")
+	site := strings.TrimSuffix(do.MustInvokeNamed[string](i, "site_url"), "/")
 
 	return &RedditPoster{
 		client:    client,
 		subreddit: subreddit,
+		site:      site,
 	}, nil
 }
 
@@ -42,7 +53,7 @@ func (p *RedditPoster) Post(ctx context.Context, params Params) error {
 	_, _, err := p.client.Post.SubmitLink(ctx, reddit.SubmitLinkRequest{
 		Subreddit:   p.subreddit,
 		Title:       fmt.Sprintf("%s - %s:%s:%s", params.Date, params.Prompt, params.Model, params.Seed),
-		URL:         fmt.Sprintf("https://kittenbot.io/%s.png", params.Date),
+		URL:         fmt.Sprintf("%s/%s", p.site, params.Image),
 		SendReplies: lo.ToPtr(false),
 	})
 	return err
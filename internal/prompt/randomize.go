@@ -2,29 +2,42 @@ package prompt
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dmorgan81/kittenbot/internal/log"
 	"github.com/samber/do"
 )
 
+// Randomizer picks a random model and prompt. It is shared by runs the server and the
+// scheduler start at the same time, so its source is guarded.
 type Randomizer struct {
 	prompts []string
+	mu      sync.Mutex
 	rnd     *rand.Rand
 }
 
 func NewRandomizer(i *do.Injector) (*Randomizer, error) {
-	prompts := do.MustInvokeNamed[[]string](i, "prompts")
+	prompts, err := do.InvokeNamed[[]string](i, "prompts")
+	if err != nil {
+		return nil, err
+	}
+	if len(prompts) == 0 {
+		return nil, errors.New("no prompts configured")
+	}
 	rnd := rand.New(rand.NewSource(time.Now().UTC().Unix()))
-	return &Randomizer{prompts, rnd}, nil
+	return &Randomizer{prompts: prompts, rnd: rnd}, nil
 }
 
 func (r *Randomizer) Randomize(ctx context.Context) (string, string, error) {
 	log := log.FromContextOrDiscard(ctx).WithGroup("randomizer")
 	log.Info("getting random model and prompt")
+	r.mu.Lock()
 	idx := r.rnd.Intn(len(r.prompts))
+	r.mu.Unlock()
 	pair := strings.Split(r.prompts[idx], "|")
 	return pair[0], pair[1], nil
 }
@@ -0,0 +1,181 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/config"
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/handler"
+	"github.com/dmorgan81/kittenbot/internal/lock"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/robfig/cron/v3"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+// Scheduler invokes the handler on a cron schedule for deployments without EventBridge.
+type Scheduler struct {
+	injector *do.Injector
+	schedule cron.Schedule
+	catchUp  time.Duration
+	locker   lock.Locker
+	reader   store.Reader
+	now      clock.Clock
+	calendar *day.Calendar
+}
+
+func NewScheduler(i *do.Injector) (*Scheduler, error) {
+	cfg := do.MustInvoke[*config.Config](i)
+	schedule, err := Parse(cfg.Schedule, lo.Ternary(cfg.ScheduleTimezone != "", cfg.ScheduleTimezone, cfg.Timezone))
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := do.Invoke[store.Reader](i)
+	if err != nil {
+		return nil, err
+	}
+	locker, err := do.Invoke[lock.Locker](i)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Scheduler{
+		injector: i,
+		schedule: schedule,
+		catchUp:  cfg.ScheduleCatchUp,
+		locker:   locker,
+		reader:   reader,
+		now:      do.MustInvoke[clock.Clock](i),
+		calendar: do.MustInvoke[*day.Calendar](i),
+	}, nil
+}
+
+// Parse reads a standard five field cron expression, or a descriptor like @daily, in the
+// given timezone. A CRON_TZ= prefix in the expression takes precedence.
+func Parse(spec, timezone string) (cron.Schedule, error) {
+	if timezone != "" && !strings.HasPrefix(spec, "CRON_TZ=") && !strings.HasPrefix(spec, "TZ=") {
+		spec = "CRON_TZ=" + timezone + " " + spec
+	}
+	return cron.ParseStandard(spec)
+}
+
+// Run fires the handler at each scheduled time until ctx is cancelled. On startup it first
+// catches up on a run missed within the catch-up window.
+func (s *Scheduler) Run(ctx context.Context) error {
+	log := log.FromContextOrDiscard(ctx).WithGroup("scheduler")
+
+	if at, ok := s.missed(ctx); ok {
+		log.Info("catching up on missed run", "at", at)
+		s.fire(ctx, at)
+	}
+
+	for {
+		next := s.schedule.Next(s.now())
+		log.Info("waiting for next run", "at", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+			s.fire(ctx, next)
+		}
+	}
+}
+
+// previous returns the latest scheduled time within the catch-up window, if any.
+func (s *Scheduler) previous(now time.Time) (time.Time, bool) {
+	var prev time.Time
+	for t := s.schedule.Next(now.Add(-s.catchUp)); !t.IsZero() && !t.After(now); t = s.schedule.Next(t) {
+		prev = t
+	}
+	return prev, !prev.IsZero()
+}
+
+// missed reports a scheduled time in the catch-up window whose day's kitten was never
+// published or staged for approval.
+func (s *Scheduler) missed(ctx context.Context) (time.Time, bool) {
+	if s.catchUp <= 0 {
+		return time.Time{}, false
+	}
+
+	prev, ok := s.previous(s.now())
+	if !ok {
+		return time.Time{}, false
+	}
+	published, err := s.published(ctx, s.calendar.Key(prev))
+	if err != nil {
+		log.FromContextOrDiscard(ctx).Error("checking for missed run", "error", err)
+		return time.Time{}, false
+	}
+	if published {
+		return time.Time{}, false
+	}
+	return prev, true
+}
+
+// published reports whether the day's kitten was published or staged for approval.
+func (s *Scheduler) published(ctx context.Context, key string) (bool, error) {
+	for _, name := range []string{key + ".html", handler.PendingManifest(key)} {
+		_, err := s.reader.Stat(ctx, name)
+		if err == nil {
+			return true, nil
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// fire runs the handler for the day of the scheduled time at, unless another instance is
+// running it or already has. A slot from an earlier day, caught up on after midnight,
+// backfills that day rather than publishing today's kitten. A run that has started is
+// finished even if ctx is cancelled meanwhile.
+func (s *Scheduler) fire(ctx context.Context, at time.Time) {
+	ctx = context.WithoutCancel(ctx)
+	key := s.calendar.Key(at)
+	log := log.FromContextOrDiscard(ctx).WithGroup("scheduler").With("at", at, "date", key)
+
+	ok, err := s.locker.Lock(ctx, key, lock.RunTTL)
+	if err != nil {
+		log.Error("acquiring lock", "error", err)
+		return
+	}
+	if !ok {
+		log.Info("another run holds the lock, skipping")
+		return
+	}
+	defer func() {
+		if err := s.locker.Unlock(ctx, key); err != nil {
+			log.Error("releasing lock", "error", err)
+		}
+	}()
+
+	if published, err := s.published(ctx, key); err != nil {
+		log.Error("checking for published run", "error", err)
+		return
+	} else if published {
+		log.Info("already published, skipping")
+		return
+	}
+
+	h, err := do.Invoke[*handler.Handler](s.injector)
+	if err != nil {
+		log.Error("building handler", "error", err)
+		return
+	}
+	var input handler.Input
+	if key != s.calendar.Key(s.now()) {
+		input.Date = key
+	}
+	if _, err := h.Handle(ctx, input); err != nil {
+		log.Error("scheduled run failed", "error", err)
+	}
+}
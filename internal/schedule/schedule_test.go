@@ -0,0 +1,140 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/lock"
+	"github.com/dmorgan81/kittenbot/internal/store"
+)
+
+func TestParseTimezone(t *testing.T) {
+	schedule, err := Parse("30 0 * * *", "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := schedule.Next(time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC))
+	want := time.Date(2023, 12, 2, 5, 30, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("got %s, want %s", got.UTC(), want)
+	}
+}
+
+func TestMissed(t *testing.T) {
+	ctx := context.Background()
+	schedule, err := Parse("30 0 * * *", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		catchUp time.Duration
+		stored  []string
+		want    time.Time
+	}{
+		{
+			name:    "missed today",
+			now:     time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC),
+			catchUp: 24 * time.Hour,
+			want:    time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC),
+		},
+		{
+			name:    "missed yesterday",
+			now:     time.Date(2023, 12, 1, 0, 10, 0, 0, time.UTC),
+			catchUp: 24 * time.Hour,
+			want:    time.Date(2023, 11, 30, 0, 30, 0, 0, time.UTC),
+		},
+		{
+			name:    "yesterday published before today's slot",
+			now:     time.Date(2023, 12, 1, 0, 10, 0, 0, time.UTC),
+			catchUp: 24 * time.Hour,
+			stored:  []string{"20231130.html"},
+		},
+		{
+			name:    "already published",
+			now:     time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC),
+			catchUp: 24 * time.Hour,
+			stored:  []string{"20231201.html"},
+		},
+		{
+			name:    "pending approval",
+			now:     time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC),
+			catchUp: 24 * time.Hour,
+			stored:  []string{"pending/20231201.manifest.json"},
+		},
+		{
+			name:    "outside window",
+			now:     time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC),
+			catchUp: time.Hour,
+		},
+		{
+			name:    "catch up disabled",
+			now:     time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC),
+			catchUp: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := func() time.Time { return tt.now }
+			s := fake.NewStore(clock)
+			for _, name := range tt.stored {
+				s.Put(store.UploadParams{Name: name})
+			}
+
+			sched := &Scheduler{schedule: schedule, catchUp: tt.catchUp, reader: s, now: clock, calendar: day.NewCalendar(time.UTC)}
+			got, ok := sched.missed(ctx)
+			if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+				t.Errorf("got %s, %v, want %s", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+// TestFireCatchUp catches up on yesterday's slot shortly before today's fires. Yesterday is
+// backfilled without posting, and today's slot then publishes and posts today once.
+func TestFireCatchUp(t *testing.T) {
+	ctx := context.Background()
+	f := fake.NewInjector(time.Date(2023, 12, 1, 0, 10, 0, 0, time.UTC))
+	i := f.Build()
+	schedule, err := Parse("30 0 * * *", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locker, _ := lock.NewStoreLocker(f.Store, f.Store, f.Store, func() time.Time { return f.Now }, 0)
+	sched := &Scheduler{
+		injector: i,
+		schedule: schedule,
+		catchUp:  24 * time.Hour,
+		locker:   locker,
+		reader:   f.Store,
+		now:      func() time.Time { return f.Now },
+		calendar: day.NewCalendar(time.UTC),
+	}
+
+	at, ok := sched.missed(ctx)
+	if !ok {
+		t.Fatal("expected yesterday's slot to be missed")
+	}
+	sched.fire(ctx, at)
+	if _, err := f.Store.Stat(ctx, "20231130.html"); err != nil {
+		t.Errorf("yesterday was not backfilled: %v", err)
+	}
+	if _, err := f.Store.Stat(ctx, "20231201.html"); err == nil {
+		t.Error("catching up on yesterday published today")
+	}
+	if len(f.Poster.Posts) != 0 {
+		t.Errorf("catching up on yesterday posted %v", f.Poster.Posts)
+	}
+
+	f.Now = time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC)
+	sched.fire(ctx, f.Now)
+	if len(f.Poster.Posts) != 1 || f.Poster.Posts[0].Date != "20231201" {
+		t.Errorf("got posts %v, want today posted once", f.Poster.Posts)
+	}
+}
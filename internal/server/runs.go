@@ -0,0 +1,118 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/handler"
+)
+
+const maxRuns = 100
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Run is a run started through the API. Action is approve or reject for runs acting on a
+// staged run and empty otherwise.
+type Run struct {
+	ID       string          `json:"id"`
+	Action   string          `json:"action,omitempty"`
+	Status   Status          `json:"status"`
+	Input    handler.Input   `json:"input"`
+	Output   *handler.Output `json:"output,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Started  time.Time       `json:"started"`
+	Finished *time.Time      `json:"finished,omitempty"`
+}
+
+// runs remembers the most recent runs in memory; older ones are forgotten.
+type runs struct {
+	mu   sync.Mutex
+	runs map[string]*Run
+}
+
+func newRuns() *runs {
+	return &runs{runs: make(map[string]*Run)}
+}
+
+func (r *runs) start(action string, input handler.Input, now time.Time) (Run, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return Run{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run := &Run{ID: hex.EncodeToString(b), Action: action, Status: StatusRunning, Input: input, Started: now}
+	r.runs[run.ID] = run
+	r.prune()
+	return *run, nil
+}
+
+func (r *runs) finish(id string, output handler.Output, err error, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run, ok := r.runs[id]
+	if !ok {
+		return
+	}
+	run.Finished = &now
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+	} else {
+		run.Status = StatusSucceeded
+		run.Output = &output
+	}
+}
+
+func (r *runs) get(id string) (Run, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run, ok := r.runs[id]
+	if !ok {
+		return Run{}, false
+	}
+	return *run, true
+}
+
+// list returns runs newest first.
+func (r *runs) list() []Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]Run, 0, len(r.runs))
+	for _, run := range r.runs {
+		list = append(list, *run)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Started.After(list[j].Started)
+	})
+	return list
+}
+
+func (r *runs) prune() {
+	if len(r.runs) <= maxRuns {
+		return
+	}
+	var oldest *Run
+	for _, run := range r.runs {
+		if run.Status != StatusRunning && (oldest == nil || run.Started.Before(oldest.Started)) {
+			oldest = run
+		}
+	}
+	if oldest != nil {
+		delete(r.runs, oldest.ID)
+	}
+}
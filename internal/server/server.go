@@ -0,0 +1,389 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/clock"
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/handler"
+	"github.com/dmorgan81/kittenbot/internal/index"
+	"github.com/dmorgan81/kittenbot/internal/lock"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+const (
+	defaultDays = 30
+	maxDays     = 365
+	// drainTimeout is how long shutting down waits for runs in progress, as long as
+	// Lambda lets a run take.
+	drainTimeout = 15 * time.Minute
+	// maxInputBytes is the largest run input accepted by POST /runs.
+	maxInputBytes = 64 << 10
+)
+
+// Server exposes an admin API for running kittenbot outside of Lambda. Every endpoint
+// requires the admin token as a bearer token.
+type Server struct {
+	injector *do.Injector
+	token    string
+	now      clock.Clock
+	calendar *day.Calendar
+	locker   lock.Locker
+	runs     *runs
+	running  sync.WaitGroup
+}
+
+func NewServer(i *do.Injector) (*Server, error) {
+	token, err := do.InvokeNamed[string](i, "admin_token")
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, errors.New("admin token must not be empty")
+	}
+	locker, err := do.Invoke[lock.Locker](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		injector: i,
+		token:    token,
+		now:      do.MustInvoke[clock.Clock](i),
+		calendar: do.MustInvoke[*day.Calendar](i),
+		locker:   locker,
+		runs:     newRuns(),
+	}, nil
+}
+
+// ListenAndServe serves until ctx is cancelled, then waits for in-flight requests and the
+// runs they started.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	log := log.FromContextOrDiscard(ctx).WithGroup("server").With("addr", addr)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Info("listening")
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		log.Info("shutting down")
+		shutdown, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		err := srv.Shutdown(shutdown)
+
+		drain, cancel := context.WithTimeout(context.WithoutCancel(ctx), drainTimeout)
+		defer cancel()
+		return errors.Join(err, s.Drain(drain))
+	}
+}
+
+// Drain waits for every run started through the API to finish, or for ctx to be done.
+func (s *Server) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("runs still in progress: %w", ctx.Err())
+	}
+}
+
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/runs", s.handleRuns)
+	mux.HandleFunc("/runs/", s.handleRun)
+	mux.HandleFunc("/days", s.handleDays)
+	mux.HandleFunc("/preview/", s.handlePreview)
+	mux.HandleFunc("/pending", s.handlePending)
+	mux.HandleFunc("/pending/", s.handlePending)
+	return s.authorize(mux)
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kittenbot"`)
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleRuns starts a run in the background on POST and lists recent runs on GET.
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.runs.list())
+	case http.MethodPost:
+		var input handler.Input
+		if r.ContentLength != 0 {
+			dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxInputBytes))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&input); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		h, err := do.Invoke[*handler.Handler](s.injector)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		date := lo.Ternary(input.Date != "", input.Date, s.calendar.Key(s.now()))
+		s.start(w, r, "", date, input, func(ctx context.Context) (handler.Output, error) {
+			return h.Handle(ctx, input)
+		})
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// start runs fn in the background holding the lock on date, the same one scheduled runs
+// take, and responds with the run it was registered as.
+func (s *Server) start(w http.ResponseWriter, r *http.Request, action, date string, input handler.Input, fn func(context.Context) (handler.Output, error)) {
+	run, err := s.runs.start(action, input, s.now())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx := context.WithoutCancel(r.Context())
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		output, err := s.locked(ctx, date, fn)
+		s.runs.finish(run.ID, output, err, s.now())
+	}()
+
+	w.Header().Set("Location", "/runs/"+run.ID)
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// locked runs fn holding the lock on date, failing with lock.ErrHeld if another run holds it.
+// Dates that are not valid keys are left for the handler to reject.
+func (s *Server) locked(ctx context.Context, date string, fn func(context.Context) (handler.Output, error)) (handler.Output, error) {
+	if !day.IsKey(date) {
+		return fn(ctx)
+	}
+	log := log.FromContextOrDiscard(ctx).WithGroup("server").With("date", date)
+
+	ok, err := s.locker.Lock(ctx, date, lock.RunTTL)
+	if err != nil {
+		return handler.Output{}, err
+	}
+	if !ok {
+		return handler.Output{}, fmt.Errorf("%s: %w", date, lock.ErrHeld)
+	}
+	defer func() {
+		if err := s.locker.Unlock(ctx, date); err != nil {
+			log.Error("releasing lock", "error", err)
+		}
+	}()
+	return fn(ctx)
+}
+
+// handlePending lists staged runs on GET /pending and shows one on GET /pending/YYYYMMDD.
+// POST /pending/YYYYMMDD/approve or /reject starts approving or rejecting it in the background.
+func (s *Server) handlePending(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/pending"), "/"), "/")
+	if len(parts) > 2 || (len(parts) == 2 && parts[1] != "approve" && parts[1] != "reject") {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	if parts[0] != "" && !day.IsKey(parts[0]) {
+		writeError(w, http.StatusBadRequest, errors.New("date must be formatted as YYYYMMDD"))
+		return
+	}
+
+	wantMethod := lo.Ternary(len(parts) == 2, http.MethodPost, http.MethodGet)
+	if r.Method != wantMethod {
+		methodNotAllowed(w, wantMethod)
+		return
+	}
+
+	h, err := do.Invoke[*handler.Handler](s.injector)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if parts[0] == "" {
+		pending, err := h.Pending(r.Context())
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, pending)
+		return
+	}
+
+	date := parts[0]
+	staged, err := h.Staged(r.Context(), date)
+	if errors.Is(err, handler.ErrNotPending) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	if len(parts) == 1 {
+		writeJSON(w, http.StatusOK, staged)
+		return
+	}
+
+	action := parts[1]
+	s.start(w, r, action, date, handler.Input(staged), func(ctx context.Context) (handler.Output, error) {
+		if action == "approve" {
+			return h.Approve(ctx, date)
+		}
+		return h.Reject(ctx, date)
+	})
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	run, ok := s.runs.get(strings.TrimPrefix(r.URL.Path, "/runs/"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("run not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+type dayInfo struct {
+	Date         string            `json:"date"`
+	Metadata     map[string]string `json:"metadata"`
+	LastModified time.Time         `json:"lastModified"`
+}
+
+// handleDays lists the most recent days, newest first, up to ?limit.
+func (s *Server) handleDays(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	limit := defaultDays
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDays {
+			writeError(w, http.StatusBadRequest, errors.New("limit must be between 1 and 365"))
+			return
+		}
+		limit = n
+	}
+
+	idx, err := do.Invoke[*index.Index](s.injector)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	keys, err := idx.Keys(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	days := make([]dayInfo, 0, limit)
+	for _, key := range lo.Slice(keys, 0, limit) {
+		d, err := idx.Day(r.Context(), key)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		days = append(days, dayInfo{
+			Date:         key,
+			Metadata:     d.Metadata,
+			LastModified: d.LastModified,
+		})
+	}
+	writeJSON(w, http.StatusOK, days)
+}
+
+// handlePreview renders the page for /preview/YYYYMMDD from the stored metadata of that
+// day's images.
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	date := strings.TrimPrefix(r.URL.Path, "/preview/")
+	if _, err := s.calendar.Parse(date); err != nil || !day.IsKey(date) {
+		writeError(w, http.StatusBadRequest, errors.New("date must be formatted as YYYYMMDD"))
+		return
+	}
+
+	h, err := do.Invoke[*handler.Handler](s.injector)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	html, err := h.Page(r.Context(), date)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, errors.New("no image for "+date))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(html)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
@@ -0,0 +1,179 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/lock"
+	"github.com/dmorgan81/kittenbot/internal/server"
+	"github.com/samber/do"
+)
+
+const token = "s3cret"
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	i := fake.NewInjector(time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC)).Build()
+	do.ProvideNamedValue[string](i, "admin_token", token)
+
+	s, err := server.NewServer(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s.Routes())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func request(t *testing.T, srv *httptest.Server, method, path, body string, v any) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestUnauthorized(t *testing.T) {
+	srv := newServer(t)
+
+	for _, auth := range []string{"", "Bearer wrong", token} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/runs", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: got status %d, want 401", auth, resp.StatusCode)
+		}
+	}
+}
+
+func TestRunThenPreview(t *testing.T) {
+	srv := newServer(t)
+
+	var run server.Run
+	if status := request(t, srv, http.MethodPost, "/runs", `{"phases":["image"],"variants":[{"seed":"1"},{"seed":"2"}],"pick":2}`, &run); status != http.StatusAccepted {
+		t.Fatalf("POST /runs: got status %d, want 202", status)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for run.Status == server.StatusRunning && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		request(t, srv, http.MethodGet, "/runs/"+run.ID, "", &run)
+	}
+	if run.Status != server.StatusSucceeded {
+		t.Fatalf("run finished with status %s: %s", run.Status, run.Error)
+	}
+
+	var days []struct {
+		Date     string            `json:"date"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	if status := request(t, srv, http.MethodGet, "/days", "", &days); status != http.StatusOK {
+		t.Fatalf("GET /days: got status %d, want 200", status)
+	}
+	if len(days) != 1 || days[0].Date != "20231201" || days[0].Metadata["prompt"] != "cute kitten" {
+		t.Errorf("GET /days: got %+v", days)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/preview/20231201", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("GET /preview: got status %d and content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	html, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(html), `<meta name="image" content="20231201-2.png">`) || !strings.Contains(string(html), `href="20231201-1.png"`) {
+		t.Errorf("GET /preview: page does not show the pick and gallery:\n%s", html)
+	}
+}
+
+// TestRunWaitsForLock starts a run while the day's lock is held, as by a scheduled run, and
+// checks it fails rather than racing it.
+func TestRunWaitsForLock(t *testing.T) {
+	ctx := context.Background()
+	i := fake.NewInjector(time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC)).Build()
+	do.ProvideNamedValue[string](i, "admin_token", token)
+	s, err := server.NewServer(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s.Routes())
+	t.Cleanup(srv.Close)
+
+	locker := do.MustInvoke[lock.Locker](i)
+	if ok, err := locker.Lock(ctx, "20231201", lock.RunTTL); err != nil || !ok {
+		t.Fatalf("locking: got %v, %v", ok, err)
+	}
+
+	var run server.Run
+	if status := request(t, srv, http.MethodPost, "/runs", `{"phases":["image"]}`, &run); status != http.StatusAccepted {
+		t.Fatalf("POST /runs: got status %d, want 202", status)
+	}
+	if err := s.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	request(t, srv, http.MethodGet, "/runs/"+run.ID, "", &run)
+	if run.Status != server.StatusFailed || !strings.Contains(run.Error, lock.ErrHeld.Error()) {
+		t.Errorf("got %s run with error %q, want it to fail on the held lock", run.Status, run.Error)
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	srv := newServer(t)
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/runs", `{"colour":"orange"}`, http.StatusBadRequest},
+		{http.MethodPost, "/runs", `{"prompt":"` + strings.Repeat("a", 64<<10) + `"}`, http.StatusBadRequest},
+		{http.MethodDelete, "/runs", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/runs/missing", "", http.StatusNotFound},
+		{http.MethodGet, "/days?limit=0", "", http.StatusBadRequest},
+		{http.MethodGet, "/preview/2023-12-01", "", http.StatusBadRequest},
+		{http.MethodGet, "/preview/20231130", "", http.StatusNotFound},
+		{http.MethodGet, "/pending/2023-11-30", "", http.StatusBadRequest},
+		{http.MethodGet, "/pending/20231130", "", http.StatusNotFound},
+		{http.MethodGet, "/pending/20231130/approve", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/pending/20231130/reject", "", http.StatusNotFound},
+		{http.MethodPost, "/pending/20231130/publish", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		var body map[string]string
+		if status := request(t, srv, tt.method, tt.path, tt.body, &body); status != tt.want {
+			t.Errorf("%s %s: got status %d, want %d (%s)", tt.method, tt.path, status, tt.want, body["error"])
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package sitemap
+
+// SetLimit lowers the number of URLs per sitemap so tests can split small sites.
+func (g *Generator) SetLimit(limit int) {
+	g.limit = limit
+}
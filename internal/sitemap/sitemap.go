@@ -0,0 +1,135 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/index"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+// maxURLs is the most URLs a single sitemap may list.
+const maxURLs = 50000
+
+const imageNamespace = "http://www.google.com/schemas/sitemap-image/1.1"
+
+// File is a generated file and the name it is published under.
+type File struct {
+	Name        string
+	Data        []byte
+	ContentType string
+}
+
+type urlSet struct {
+	XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	Image   string   `xml:"xmlns:image,attr"`
+	URLs    []url    `xml:"url"`
+}
+
+type url struct {
+	Loc     string  `xml:"loc"`
+	LastMod string  `xml:"lastmod,omitempty"`
+	Images  []image `xml:"image:image"`
+}
+
+type image struct {
+	Loc string `xml:"image:loc"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []entry  `xml:"sitemap"`
+}
+
+type entry struct {
+	Loc string `xml:"loc"`
+}
+
+// Generator builds sitemap.xml, listing every published day and its images, and robots.txt
+// pointing at it.
+type Generator struct {
+	index *index.Index
+	site  string
+	limit int
+}
+
+func NewGenerator(i *do.Injector) (*Generator, error) {
+	index, err := do.Invoke[*index.Index](i)
+	if err != nil {
+		return nil, err
+	}
+	site := strings.TrimSuffix(do.MustInvokeNamed[string](i, "site_url"), "/")
+	return &Generator{index, site, maxURLs}, nil
+}
+
+// Generate returns sitemap.xml and robots.txt. Past the limit of a single sitemap the URLs
+// are split across sitemap-1.xml, sitemap-2.xml and so on, and sitemap.xml indexes them.
+func (g *Generator) Generate(ctx context.Context) ([]File, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("sitemap")
+	log.Info("generating sitemap")
+
+	days, err := g.index.Days(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := []url{{Loc: g.site + "/"}, {Loc: g.site + "/archive.html"}}
+	for _, d := range days {
+		urls = append(urls, url{
+			Loc:     g.site + "/" + d.Page(),
+			LastMod: d.LastModified.UTC().Format(time.RFC3339),
+			Images: lo.Map(d.Images(), func(name string, _ int) image {
+				return image{g.site + "/" + name}
+			}),
+		})
+	}
+
+	var files []File
+	chunks := lo.Chunk(urls, g.limit)
+	if len(chunks) == 1 {
+		data, err := encode(urlSet{Image: imageNamespace, URLs: urls})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{"sitemap.xml", data, "application/xml"})
+	} else {
+		var idx sitemapIndex
+		for n, chunk := range chunks {
+			name := fmt.Sprintf("sitemap-%d.xml", n+1)
+			data, err := encode(urlSet{Image: imageNamespace, URLs: chunk})
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, File{name, data, "application/xml"})
+			idx.Sitemaps = append(idx.Sitemaps, entry{g.site + "/" + name})
+		}
+		data, err := encode(idx)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{"sitemap.xml", data, "application/xml"})
+	}
+
+	robots := fmt.Sprintf("User-agent: *\nAllow: /\n\nSitemap: %s/sitemap.xml\n", g.site)
+	files = append(files, File{"robots.txt", []byte(robots), "text/plain"})
+	log.Info("generated sitemap", "urls", len(urls), "files", len(files))
+	return files, nil
+}
+
+func encode(v any) ([]byte, error) {
+	var data bytes.Buffer
+	data.WriteString(xml.Header)
+	enc := xml.NewEncoder(&data)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	data.WriteByte('\n')
+	return data.Bytes(), nil
+}
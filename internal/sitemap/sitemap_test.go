@@ -0,0 +1,78 @@
+package sitemap_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/sitemap"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+)
+
+func generator(t *testing.T) *sitemap.Generator {
+	t.Helper()
+	f := fake.NewInjector(time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC))
+	f.Store.Put(store.UploadParams{Name: "20231201.html", Metadata: map[string]string{"date": "20231201", "variants": "2", "pick": "1"}})
+	// Relinking re-renders a page after its day.
+	f.Now = time.Date(2023, 12, 2, 0, 30, 0, 0, time.UTC)
+	f.Store.Put(store.UploadParams{Name: "20231130.html", Metadata: map[string]string{"date": "20231130"}})
+	f.Store.Put(store.UploadParams{Name: "latest.html"})
+	return do.MustInvoke[*sitemap.Generator](f.Build())
+}
+
+func TestGenerate(t *testing.T) {
+	files, err := generator(t).Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Name != "sitemap.xml" || files[1].Name != "robots.txt" {
+		t.Fatalf("got %d files, want sitemap.xml and robots.txt", len(files))
+	}
+
+	xml := string(files[0].Data)
+	for _, want := range []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">`,
+		"<loc>https://kittenbot.io/</loc>",
+		"<loc>https://kittenbot.io/20231130.html</loc>",
+		"<image:loc>https://kittenbot.io/20231130.png</image:loc>",
+		"<loc>https://kittenbot.io/20231130.html</loc>\n    <lastmod>2023-12-02T00:30:00Z</lastmod>",
+		"<loc>https://kittenbot.io/20231201.html</loc>\n    <lastmod>2023-12-01T00:30:00Z</lastmod>",
+		"<image:loc>https://kittenbot.io/20231201-2.png</image:loc>",
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("sitemap is missing %s:\n%s", want, xml)
+		}
+	}
+	if strings.Contains(xml, "latest.html") {
+		t.Errorf("sitemap lists latest.html:\n%s", xml)
+	}
+	if robots := string(files[1].Data); !strings.Contains(robots, "Sitemap: https://kittenbot.io/sitemap.xml\n") {
+		t.Errorf("robots.txt does not point at the sitemap:\n%s", robots)
+	}
+}
+
+func TestGenerateSplit(t *testing.T) {
+	g := generator(t)
+	g.SetLimit(3)
+	files, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if got, want := strings.Join(names, ","), "sitemap-1.xml,sitemap-2.xml,sitemap.xml,robots.txt"; got != want {
+		t.Fatalf("got files %s, want %s", got, want)
+	}
+	index := string(files[2].Data)
+	for _, want := range []string{"<sitemapindex", "<loc>https://kittenbot.io/sitemap-2.xml</loc>"} {
+		if !strings.Contains(index, want) {
+			t.Errorf("sitemap index is missing %s:\n%s", want, index)
+		}
+	}
+}
@@ -3,6 +3,13 @@ package store
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -12,66 +19,278 @@ import (
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/dmorgan81/kittenbot/internal/log"
 	"github.com/samber/do"
+	"github.com/samber/lo"
 )
 
-type S3Uploader struct {
-	client *s3.Client
-	bucket string
+type S3Store struct {
+	client       *s3.Client
+	bucket       string
+	storageClass s3types.StorageClass
 }
 
-func NewS3Uploader(i *do.Injector) (Uploader, error) {
-	client := do.MustInvoke[*s3.Client](i)
+func newS3Store(i *do.Injector) (*S3Store, error) {
+	client, err := do.Invoke[*s3.Client](i)
+	if err != nil {
+		return nil, err
+	}
 	bucket := do.MustInvokeNamed[string](i, "bucket")
-	return &S3Uploader{client, bucket}, nil
+	storageClass := s3types.StorageClass(do.MustInvokeNamed[string](i, "storage_class"))
+	return &S3Store{client, bucket, storageClass}, nil
+}
+
+func NewS3Uploader(i *do.Injector) (Uploader, error) {
+	return newS3Store(i)
 }
 
-func (u *S3Uploader) Upload(ctx context.Context, params UploadParams) error {
+func NewS3Copier(i *do.Injector) (Copier, error) {
+	return newS3Store(i)
+}
+
+func NewS3Deleter(i *do.Injector) (Deleter, error) {
+	return newS3Store(i)
+}
+
+func NewS3Reader(i *do.Injector) (Reader, error) {
+	return newS3Store(i)
+}
+
+func (u *S3Store) Upload(ctx context.Context, params UploadParams) error {
 	log := log.FromContextOrDiscard(ctx).WithGroup("s3 uploader").With(
 		"name", params.Name,
 		"content-type", params.ContentType,
+		"cache-control", params.CacheControl,
 		"metadata", params.Metadata,
 		"bucket", u.bucket,
 	)
 	log.Info("uploading")
 
 	_, err := u.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:       aws.String(u.bucket),
-		Key:          aws.String(params.Name),
-		ContentType:  aws.String(params.ContentType),
-		Body:         bytes.NewReader(params.Data),
-		Metadata:     params.Metadata,
-		StorageClass: s3types.StorageClassIntelligentTiering,
+		Bucket:             aws.String(u.bucket),
+		Key:                aws.String(params.Name),
+		ContentType:        aws.String(params.ContentType),
+		CacheControl:       optional(params.CacheControl),
+		ContentDisposition: optional(params.ContentDisposition),
+		ContentEncoding:    optional(params.ContentEncoding),
+		Body:               bytes.NewReader(params.Data),
+		Metadata:           params.Metadata,
+		StorageClass:       u.storageClass,
+	})
+	return err
+}
+
+func (u *S3Store) Copy(ctx context.Context, params CopyParams) error {
+	log := log.FromContextOrDiscard(ctx).WithGroup("s3 copier").With(
+		"source", params.Source,
+		"name", params.Name,
+		"cache-control", params.CacheControl,
+		"bucket", u.bucket,
+	)
+	log.Info("copying")
+
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(u.bucket),
+		Key:               aws.String(params.Name),
+		CopySource:        aws.String(u.bucket + "/" + url.PathEscape(params.Source)),
+		MetadataDirective: s3types.MetadataDirectiveCopy,
+		StorageClass:      u.storageClass,
+	}
+
+	// S3 only lets a copy change headers by replacing all of them, so carry over the rest from the source.
+	if params.CacheControl != "" {
+		src, err := u.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(u.bucket),
+			Key:    aws.String(params.Source),
+		})
+		if err != nil {
+			return err
+		}
+		input.MetadataDirective = s3types.MetadataDirectiveReplace
+		input.CacheControl = aws.String(params.CacheControl)
+		input.ContentType = src.ContentType
+		input.ContentDisposition = src.ContentDisposition
+		input.ContentEncoding = src.ContentEncoding
+		input.Metadata = src.Metadata
+	}
+
+	_, err := u.client.CopyObject(ctx, input)
+	return err
+}
+
+func (u *S3Store) Delete(ctx context.Context, name string) error {
+	log := log.FromContextOrDiscard(ctx).WithGroup("s3 deleter").With("name", name, "bucket", u.bucket)
+	log.Info("deleting")
+
+	_, err := u.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(u.bucket),
+		Key:    aws.String(name),
 	})
 	return err
 }
 
+func (u *S3Store) Stat(ctx context.Context, name string) (Object, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("s3 reader").With("name", name, "bucket", u.bucket)
+	log.Info("reading metadata")
+
+	out, err := u.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(u.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		var nf *s3types.NotFound
+		if errors.As(err, &nf) {
+			return Object{}, ErrNotFound
+		}
+		return Object{}, err
+	}
+	return Object{
+		Name:         name,
+		Metadata:     out.Metadata,
+		LastModified: aws.ToTime(out.LastModified),
+	}, nil
+}
+
+func (u *S3Store) Read(ctx context.Context, name string) ([]byte, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("s3 reader").With("name", name, "bucket", u.bucket)
+	log.Info("reading")
+
+	out, err := u.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(u.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		var nsk *s3types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+func (u *S3Store) List(ctx context.Context, params ListParams) ([]string, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("s3 reader").With(
+		"prefix", params.Prefix,
+		"start-after", params.StartAfter,
+		"delimiter", params.Delimiter,
+		"bucket", u.bucket,
+	)
+	log.Info("listing objects")
+
+	var names []string
+	pager := s3.NewListObjectsV2Paginator(u.client, &s3.ListObjectsV2Input{
+		Bucket:     aws.String(u.bucket),
+		Prefix:     optional(params.Prefix),
+		StartAfter: optional(params.StartAfter),
+		Delimiter:  optional(params.Delimiter),
+	})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			names = append(names, aws.ToString(obj.Key))
+		}
+	}
+	return names, nil
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// maxCloudFrontPaths is the point past which invalidating everything is cheaper than
+// paying for each path individually.
+const maxCloudFrontPaths = 15
+
 type CloudFrontInvalidator struct {
 	client       *cloudfront.Client
 	distribution string
+	wait         time.Duration
 }
 
 func NewCloudFrontInvalidator(i *do.Injector) (Invalidator, error) {
-	client := do.MustInvoke[*cloudfront.Client](i)
+	client, err := do.Invoke[*cloudfront.Client](i)
+	if err != nil {
+		return nil, err
+	}
 	distribution := do.MustInvokeNamed[string](i, "distribution")
-	return &CloudFrontInvalidator{client, distribution}, nil
+	wait := do.MustInvokeNamed[time.Duration](i, "invalidation_wait")
+	return &CloudFrontInvalidator{client, distribution, wait}, nil
 }
 
 func (i *CloudFrontInvalidator) Invalidate(ctx context.Context, paths []string) error {
+	paths = collapsePaths(paths)
 	log := log.FromContextOrDiscard(ctx).WithGroup("cloudfront invalidator").With(
 		"paths", paths,
 		"distribution", i.distribution,
 	)
 	log.Info("invalidating paths in cloudfront")
 
-	_, err := i.client.CreateInvalidation(ctx, &cloudfront.CreateInvalidationInput{
+	ref, err := callerReference()
+	if err != nil {
+		return err
+	}
+
+	out, err := i.client.CreateInvalidation(ctx, &cloudfront.CreateInvalidationInput{
 		DistributionId: aws.String(i.distribution),
 		InvalidationBatch: &cftypes.InvalidationBatch{
-			CallerReference: aws.String(time.Now().UTC().Format("20060102150405")),
+			CallerReference: aws.String(ref),
 			Paths: &cftypes.Paths{
 				Quantity: aws.Int32(int32(len(paths))),
 				Items:    paths,
 			},
 		},
 	})
-	return err
+	if err != nil || i.wait <= 0 {
+		return err
+	}
+
+	log.Info("waiting for invalidation to complete", "id", aws.ToString(out.Invalidation.Id), "timeout", i.wait)
+	return cloudfront.NewInvalidationCompletedWaiter(i.client).Wait(ctx, &cloudfront.GetInvalidationInput{
+		DistributionId: aws.String(i.distribution),
+		Id:             out.Invalidation.Id,
+	}, i.wait)
+}
+
+// collapsePaths dedupes paths and replaces every group of paths sharing a name but not an
+// extension, like /latest.png and /latest.html, with a single wildcard. CloudFront bills a
+// wildcard as one path. Too many paths collapse to /* altogether.
+func collapsePaths(paths []string) []string {
+	groups := make(map[string][]string)
+	var stems []string
+	for _, p := range lo.Uniq(paths) {
+		stem := strings.TrimSuffix(p, path.Ext(p))
+		if _, ok := groups[stem]; !ok {
+			stems = append(stems, stem)
+		}
+		groups[stem] = append(groups[stem], p)
+	}
+
+	collapsed := make([]string, 0, len(stems))
+	for _, stem := range stems {
+		if group := groups[stem]; len(group) > 1 {
+			collapsed = append(collapsed, stem+".*")
+		} else {
+			collapsed = append(collapsed, group[0])
+		}
+	}
+
+	if len(collapsed) > maxCloudFrontPaths {
+		return []string{"/*"}
+	}
+	return collapsed
+}
+
+// callerReference is unique per call, even for calls made within the same second.
+func callerReference() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return time.Now().UTC().Format("20060102150405") + "-" + hex.EncodeToString(b), nil
 }
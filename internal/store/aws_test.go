@@ -0,0 +1,101 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+)
+
+// TestS3List follows every page of a listing from a stand-in S3 that returns two keys at a
+// time, passing the prefix and delimiter on so S3 leaves out what the listing does not need.
+func TestS3List(t *testing.T) {
+	keys := []string{"20231129.html", "20231130.html", "20231201.html", "latest.html", "pending/20231202.manifest.json"}
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/kittenbot" || q.Get("list-type") != "2" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, r.URL.RawQuery)
+
+		after := q.Get("start-after")
+		if token := q.Get("continuation-token"); token != "" {
+			after = token
+		}
+		var listed, page []string
+		for _, k := range keys {
+			rest, ok := strings.CutPrefix(k, q.Get("prefix"))
+			if ok && (q.Get("delimiter") == "" || !strings.Contains(rest, q.Get("delimiter"))) {
+				listed = append(listed, k)
+			}
+		}
+		for _, k := range listed {
+			if k > after && len(page) < 2 {
+				page = append(page, k)
+			}
+		}
+		truncated := len(page) == 2 && page[1] != listed[len(listed)-1]
+
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>kittenbot</Name>`)
+		for _, k := range page {
+			fmt.Fprintf(&b, "<Contents><Key>%s</Key></Contents>", k)
+		}
+		fmt.Fprintf(&b, "<KeyCount>%d</KeyCount><IsTruncated>%t</IsTruncated>", len(page), truncated)
+		if truncated {
+			fmt.Fprintf(&b, "<NextContinuationToken>%s</NextContinuationToken>", page[1])
+		}
+		b.WriteString("</ListBucketResult>")
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(b.String()))
+	}))
+	defer srv.Close()
+
+	i := do.New()
+	do.ProvideValue(i, s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		UsePathStyle: true,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "id", SecretAccessKey: "secret"}, nil
+		}),
+	}))
+	do.ProvideNamedValue[string](i, "bucket", "kittenbot")
+	do.ProvideNamedValue[string](i, "storage_class", "STANDARD")
+	reader, err := store.NewS3Reader(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := reader.List(context.Background(), store.ListParams{StartAfter: "20231129.html", Delimiter: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(names, ","), "20231130.html,20231201.html,latest.html"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if len(requests) != 2 || !strings.Contains(requests[0], "delimiter=%2F") {
+		t.Errorf("got requests %q, want 2 pages of a single level", requests)
+	}
+
+	requests = nil
+	names, err = reader.List(context.Background(), store.ListParams{Prefix: "pending/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(names, ","), "pending/20231202.manifest.json"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if len(requests) != 1 || !strings.Contains(requests[0], "prefix=pending%2F") {
+		t.Errorf("got requests %q, want one for the prefix", requests)
+	}
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+// cloudflareMaxFiles is the most URLs Cloudflare accepts in a single purge request.
+const cloudflareMaxFiles = 30
+
+type CloudflareInvalidator struct {
+	client   *http.Client
+	endpoint string
+	zone     string
+	token    string
+	site     string
+}
+
+func NewCloudflareInvalidator(i *do.Injector) (Invalidator, error) {
+	token, err := do.InvokeNamed[string](i, "cloudflare_token")
+	if err != nil {
+		return nil, err
+	}
+	return &CloudflareInvalidator{
+		client:   &http.Client{},
+		endpoint: strings.TrimSuffix(do.MustInvokeNamed[string](i, "cloudflare_url"), "/"),
+		zone:     do.MustInvokeNamed[string](i, "cloudflare_zone"),
+		token:    token,
+		site:     do.MustInvokeNamed[string](i, "site_url"),
+	}, nil
+}
+
+type cloudflareError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type cloudflareResponse struct {
+	Success bool              `json:"success"`
+	Errors  []cloudflareError `json:"errors"`
+}
+
+func (i *CloudflareInvalidator) Invalidate(ctx context.Context, paths []string) error {
+	log := log.FromContextOrDiscard(ctx).WithGroup("cloudflare invalidator").With(
+		"paths", paths,
+		"zone", i.zone,
+	)
+	log.Info("purging paths in cloudflare")
+
+	urls := lo.Map(lo.Uniq(paths), func(p string, _ int) string {
+		return strings.TrimSuffix(i.site, "/") + p
+	})
+	for _, chunk := range lo.Chunk(urls, cloudflareMaxFiles) {
+		if err := i.purge(ctx, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *CloudflareInvalidator) purge(ctx context.Context, urls []string) error {
+	body, err := json.Marshal(map[string][]string{"files": urls})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/zones/%s/purge_cache", i.endpoint, i.zone), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", "Bearer "+i.token)
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var out cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("cloudflare purge: %s: %w", resp.Status, err)
+	}
+	if !out.Success {
+		msgs := lo.Map(out.Errors, func(e cloudflareError, _ int) string {
+			return fmt.Sprintf("%d %s", e.Code, e.Message)
+		})
+		return fmt.Errorf("cloudflare purge: %s: %s", resp.Status, strings.Join(msgs, ", "))
+	}
+	return nil
+}
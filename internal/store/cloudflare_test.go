@@ -0,0 +1,123 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+)
+
+func TestCloudflareInvalidator(t *testing.T) {
+	var purged [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/zones/zone/purge_cache" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"success": false, "errors": []map[string]any{{"code": 10000, "message": "Authentication error"}}})
+			return
+		}
+		var body struct {
+			Files []string `json:"files"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		purged = append(purged, body.Files)
+		json.NewEncoder(w).Encode(map[string]any{"success": true})
+	}))
+	defer srv.Close()
+
+	i := do.New()
+	do.ProvideNamedValue[string](i, "cloudflare_url", srv.URL+"/")
+	do.ProvideNamedValue[string](i, "cloudflare_zone", "zone")
+	do.ProvideNamedValue[string](i, "cloudflare_token", "token")
+	do.ProvideNamedValue[string](i, "site_url", "https://kittenbot.io/")
+	invalidator, err := store.NewCloudflareInvalidator(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{"/latest.html", "/latest.html"}
+	for n := 0; n < 34; n++ {
+		paths = append(paths, fmt.Sprintf("/202311%02d.html", n))
+	}
+	if err := invalidator.Invalidate(context.Background(), paths); err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 2 || len(purged[0]) != 30 || len(purged[1]) != 5 {
+		t.Fatalf("got batches of %v, want 30 then 5 urls", lengths(purged))
+	}
+	if purged[0][0] != "https://kittenbot.io/latest.html" {
+		t.Errorf("got url %q", purged[0][0])
+	}
+}
+
+func TestCloudflareInvalidatorErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name: "api error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]any{"success": false, "errors": []map[string]any{{"code": 10000, "message": "Authentication error"}}})
+			},
+			want: "cloudflare purge: 403 Forbidden: 10000 Authentication error",
+		},
+		{
+			name: "not json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			},
+			want: "cloudflare purge: 502 Bad Gateway",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				tt.handler(w, r)
+			}))
+			defer srv.Close()
+
+			i := do.New()
+			do.ProvideNamedValue[string](i, "cloudflare_url", srv.URL)
+			do.ProvideNamedValue[string](i, "cloudflare_zone", "zone")
+			do.ProvideNamedValue[string](i, "cloudflare_token", "token")
+			do.ProvideNamedValue[string](i, "site_url", "https://kittenbot.io")
+			invalidator, err := store.NewCloudflareInvalidator(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			paths := make([]string, 0, 40)
+			for n := 0; n < 40; n++ {
+				paths = append(paths, fmt.Sprintf("/%d.html", n))
+			}
+			err = invalidator.Invalidate(context.Background(), paths)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+			if requests != 1 {
+				t.Errorf("got %d requests, want to stop after the first failed batch", requests)
+			}
+		})
+	}
+}
+
+func lengths(batches [][]string) []int {
+	n := make([]int, 0, len(batches))
+	for _, b := range batches {
+		n = append(n, len(b))
+	}
+	return n
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCollapsePaths(t *testing.T) {
+	// distinct returns n paths that share no stem.
+	distinct := func(n int) []string {
+		paths := make([]string, n)
+		for idx := range paths {
+			paths[idx] = fmt.Sprintf("/2023%04d.html", idx)
+		}
+		return paths
+	}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{"empty", nil, []string{}},
+		{"dedupe", []string{"/feed.xml", "/feed.xml", "/20231201.html"}, []string{"/feed.xml", "/20231201.html"}},
+		{"wildcard", []string{"/latest.png", "/latest.html", "/feed.xml"}, []string{"/latest.*", "/feed.xml"}},
+		{"wildcard after dedupe", []string{"/latest.png", "/latest.png", "/feed.xml"}, []string{"/latest.png", "/feed.xml"}},
+		{"wildcard keeps directories apart", []string{"/latest.html", "/api/latest.json"}, []string{"/latest.html", "/api/latest.json"}},
+		{"at the limit", distinct(maxCloudFrontPaths), distinct(maxCloudFrontPaths)},
+		{"past the limit", distinct(maxCloudFrontPaths + 1), []string{"/*"}},
+		{"collapsed to the limit", append(distinct(maxCloudFrontPaths), "/20230000.png"), append([]string{"/20230000.*"}, distinct(maxCloudFrontPaths)[1:]...)},
+	}
+	for _, tt := range tests {
+		if got := collapsePaths(tt.paths); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package store
+
+import (
+	"context"
+)
+
+// CopyParams names the object to copy from and to. A non-empty CacheControl replaces the
+// source's value on the copy; content type and metadata are always carried over.
+type CopyParams struct {
+	Source       string
+	Name         string
+	CacheControl string
+}
+
+// Copier duplicates an object already in the store without re-sending its bytes.
+type Copier interface {
+	Copy(context.Context, CopyParams) error
+}
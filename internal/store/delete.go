@@ -0,0 +1,9 @@
+package store
+
+import (
+	"context"
+)
+
+type Deleter interface {
+	Delete(context.Context, string) error
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+type FastlyInvalidator struct {
+	client   *http.Client
+	endpoint string
+	key      string
+	host     string
+}
+
+func NewFastlyInvalidator(i *do.Injector) (Invalidator, error) {
+	site, err := url.Parse(do.MustInvokeNamed[string](i, "site_url"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := do.InvokeNamed[string](i, "fastly_key")
+	if err != nil {
+		return nil, err
+	}
+	return &FastlyInvalidator{
+		client:   &http.Client{},
+		endpoint: strings.TrimSuffix(do.MustInvokeNamed[string](i, "fastly_url"), "/"),
+		key:      key,
+		host:     site.Host,
+	}, nil
+}
+
+func (i *FastlyInvalidator) Invalidate(ctx context.Context, paths []string) error {
+	log := log.FromContextOrDiscard(ctx).WithGroup("fastly invalidator").With(
+		"paths", paths,
+		"host", i.host,
+	)
+	log.Info("purging paths in fastly")
+
+	for _, p := range lo.Uniq(paths) {
+		if err := i.purge(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purge issues a single URL purge; Fastly identifies the object by host and path without a scheme.
+func (i *FastlyInvalidator) purge(ctx context.Context, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/purge/%s%s", i.endpoint, i.host, path), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Fastly-Key", i.key)
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("fastly purge %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package store_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+)
+
+func fastly(t *testing.T, handler http.HandlerFunc) (store.Invalidator, *[]string) {
+	t.Helper()
+	var purged []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Fastly-Key") != "key" {
+			http.Error(w, `{"msg":"Provided credentials are missing or invalid"}`, http.StatusUnauthorized)
+			return
+		}
+		purged = append(purged, r.URL.Path)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	i := do.New()
+	do.ProvideNamedValue[string](i, "fastly_url", srv.URL+"/")
+	do.ProvideNamedValue[string](i, "fastly_key", "key")
+	do.ProvideNamedValue[string](i, "site_url", "https://kittenbot.io")
+	invalidator, err := store.NewFastlyInvalidator(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return invalidator, &purged
+}
+
+func TestFastlyInvalidator(t *testing.T) {
+	invalidator, purged := fastly(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+	if err := invalidator.Invalidate(context.Background(), []string{"/latest.html", "/latest.png", "/latest.html"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/purge/kittenbot.io/latest.html", "/purge/kittenbot.io/latest.png"}
+	if len(*purged) != len(want) || (*purged)[0] != want[0] || (*purged)[1] != want[1] {
+		t.Errorf("got purges %q, want %q", *purged, want)
+	}
+}
+
+func TestFastlyInvalidatorError(t *testing.T) {
+	invalidator, purged := fastly(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"msg":"Rate limit exceeded"}`, http.StatusTooManyRequests)
+	})
+	err := invalidator.Invalidate(context.Background(), []string{"/latest.html", "/latest.png"})
+	want := `fastly purge /latest.html: 429 Too Many Requests: {"msg":"Rate limit exceeded"}`
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+	if len(*purged) != 1 {
+		t.Errorf("got %d purges, want to stop after the first failure", len(*purged))
+	}
+}
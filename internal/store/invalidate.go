@@ -7,3 +7,31 @@ import (
 type Invalidator interface {
 	Invalidate(context.Context, []string) error
 }
+
+// Paths collects invalidation paths across phases so they can be sent in a single
+// request. Duplicates are dropped and insertion order is kept.
+type Paths struct {
+	seen  map[string]struct{}
+	items []string
+}
+
+func (p *Paths) Add(paths ...string) {
+	if p.seen == nil {
+		p.seen = make(map[string]struct{})
+	}
+	for _, path := range paths {
+		if _, ok := p.seen[path]; ok {
+			continue
+		}
+		p.seen[path] = struct{}{}
+		p.items = append(p.items, path)
+	}
+}
+
+func (p *Paths) Items() []string {
+	return p.items
+}
+
+func (p *Paths) Len() int {
+	return len(p.items)
+}
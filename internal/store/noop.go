@@ -0,0 +1,21 @@
+package store
+
+import (
+	"context"
+
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/samber/do"
+)
+
+// NoopInvalidator is used when the site is not behind a CDN, or the CDN is purged some other way.
+type NoopInvalidator struct{}
+
+func NewNoopInvalidator(i *do.Injector) (Invalidator, error) {
+	return NoopInvalidator{}, nil
+}
+
+func (NoopInvalidator) Invalidate(ctx context.Context, paths []string) error {
+	log := log.FromContextOrDiscard(ctx).WithGroup("noop invalidator")
+	log.Info("skipping invalidation", "paths", paths)
+	return nil
+}
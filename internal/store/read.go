@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+type Object struct {
+	Name         string
+	Metadata     map[string]string
+	LastModified time.Time
+}
+
+// ListParams narrows a listing to the names starting with Prefix that sort after StartAfter.
+// With a Delimiter, names holding it after the prefix are left out, so "/" lists a single
+// level without the objects under pending/, ledger/ and the like.
+type ListParams struct {
+	Prefix     string
+	StartAfter string
+	Delimiter  string
+}
+
+// Reader looks up objects in the store. Missing objects are reported as ErrNotFound.
+type Reader interface {
+	Stat(context.Context, string) (Object, error)
+	// Read returns the content of an object.
+	Read(context.Context, string) ([]byte, error)
+	// List returns the names of the objects params selects, in order.
+	List(context.Context, ListParams) ([]string, error)
+}
@@ -4,11 +4,22 @@ import (
 	"context"
 )
 
+// Cache-Control values for objects whose content never changes once written, for
+// objects that are overwritten on every run and for objects that must never be cached.
+const (
+	CacheImmutable = "public, max-age=31536000, immutable"
+	CacheShort     = "public, max-age=300"
+	CacheNone      = "no-store"
+)
+
 type UploadParams struct {
-	Name        string
-	Data        []byte
-	ContentType string
-	Metadata    map[string]string
+	Name               string
+	Data               []byte
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	Metadata           map[string]string
 }
 
 type Uploader interface {
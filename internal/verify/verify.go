@@ -0,0 +1,148 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dmorgan81/kittenbot/internal/day"
+	"github.com/dmorgan81/kittenbot/internal/image"
+	"github.com/dmorgan81/kittenbot/internal/index"
+	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/phash"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+// ErrNotVerified is returned along with a report when any image of the day could not be
+// reproduced or no longer matches what was published.
+var ErrNotVerified = errors.New("published images could not be verified")
+
+// Result compares a published image with the one regenerated from its metadata. Similarity
+// is 1 - Distance/64, where Distance is the Hamming distance between their perceptual
+// hashes. Tampered means the stored image no longer has the hash recorded when it was
+// published.
+type Result struct {
+	Name       string  `json:"name"`
+	Model      string  `json:"model"`
+	Prompt     string  `json:"prompt"`
+	Seed       string  `json:"seed"`
+	Distance   int     `json:"distance"`
+	Similarity float64 `json:"similarity"`
+	Identical  bool    `json:"identical"`
+	Tampered   bool    `json:"tampered"`
+	Reproduced bool    `json:"reproduced"`
+}
+
+// Report holds the results for every image of a day. Verified means every image was
+// reproduced and none was tampered with.
+type Report struct {
+	Date     string   `json:"date"`
+	Results  []Result `json:"results"`
+	Verified bool     `json:"verified"`
+}
+
+// Verifier regenerates published images with the configured generator to detect provider
+// drift or objects changed after they were published.
+type Verifier struct {
+	index     *index.Index
+	reader    store.Reader
+	generator image.Generator
+	distance  int
+}
+
+func NewVerifier(i *do.Injector) (*Verifier, error) {
+	index, err := do.Invoke[*index.Index](i)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := do.Invoke[store.Reader](i)
+	if err != nil {
+		return nil, err
+	}
+	generator, err := do.Invoke[image.Generator](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{index, reader, generator, do.MustInvokeNamed[int](i, "verify_distance")}, nil
+}
+
+// Verify regenerates every image of a published day. Images regenerated within the
+// configured distance of the published one count as reproduced.
+func (v *Verifier) Verify(ctx context.Context, date string) (Report, error) {
+	log := log.FromContextOrDiscard(ctx).WithGroup("verify").With("date", date)
+	log.Info("verifying published images")
+
+	if !day.IsKey(date) {
+		return Report{}, fmt.Errorf("date %q must be formatted as YYYYMMDD", date)
+	}
+	d, err := v.index.Day(ctx, date)
+	if err != nil {
+		return Report{}, fmt.Errorf("%s: %w", date, err)
+	}
+
+	report := Report{Date: date}
+	for _, name := range d.Images() {
+		result, err := v.verify(ctx, name)
+		if err != nil {
+			return Report{}, fmt.Errorf("%s: %w", name, err)
+		}
+		log.Info("verified image", "result", result)
+		report.Results = append(report.Results, result)
+	}
+
+	report.Verified = lo.EveryBy(report.Results, func(r Result) bool {
+		return r.Reproduced && !r.Tampered
+	})
+	if !report.Verified {
+		return report, ErrNotVerified
+	}
+	return report, nil
+}
+
+func (v *Verifier) verify(ctx context.Context, name string) (Result, error) {
+	obj, err := v.reader.Stat(ctx, name)
+	if err != nil {
+		return Result{}, err
+	}
+	params := image.Params{Model: obj.Metadata["model"], Prompt: obj.Metadata["prompt"], Seed: obj.Metadata["seed"]}
+	if params.Model == "" || params.Prompt == "" || params.Seed == "" {
+		return Result{}, errors.New("metadata is missing the model, prompt or seed")
+	}
+
+	published, err := v.reader.Read(ctx, name)
+	if err != nil {
+		return Result{}, err
+	}
+	publishedHash, err := phash.DHash(published)
+	if err != nil {
+		return Result{}, err
+	}
+
+	regenerated, err := v.generator.Generate(ctx, params)
+	if err != nil {
+		return Result{}, err
+	}
+	regeneratedHash, err := phash.DHash(regenerated.Data)
+	if err != nil {
+		return Result{}, err
+	}
+
+	distance := phash.Distance(publishedHash, regeneratedHash)
+	result := Result{
+		Name:       name,
+		Model:      params.Model,
+		Prompt:     params.Prompt,
+		Seed:       params.Seed,
+		Distance:   distance,
+		Similarity: 1 - float64(distance)/64,
+		Identical:  bytes.Equal(published, regenerated.Data),
+		Reproduced: distance <= v.distance,
+	}
+	if recorded, err := phash.Parse(obj.Metadata["dhash"]); err == nil {
+		result.Tampered = recorded != publishedHash
+	}
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package verify_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dmorgan81/kittenbot/internal/fake"
+	"github.com/dmorgan81/kittenbot/internal/handler"
+	"github.com/dmorgan81/kittenbot/internal/store"
+	"github.com/dmorgan81/kittenbot/internal/verify"
+	"github.com/samber/do"
+	"github.com/samber/lo"
+)
+
+var now = time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC)
+
+// publish runs the image phase for today with two variants.
+func publish(t *testing.T, f *fake.Injector) *verify.Verifier {
+	t.Helper()
+	i := f.Build()
+	input := handler.Input{Variants: []handler.Variant{{Seed: "1"}, {Seed: "2"}}, Phases: []handler.Phase{handler.PhaseImage}}
+	if _, err := do.MustInvoke[*handler.Handler](i).Handle(context.Background(), input); err != nil {
+		t.Fatal(err)
+	}
+	return do.MustInvoke[*verify.Verifier](i)
+}
+
+func TestVerify(t *testing.T) {
+	f := fake.NewInjector(now)
+	verifier := publish(t, f)
+
+	report, err := verifier.Verify(context.Background(), "20231201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(report.Results))
+	}
+	for _, r := range report.Results {
+		if !r.Identical || !r.Reproduced || r.Tampered || r.Similarity != 1 {
+			t.Errorf("got %+v, want an identical reproduction", r)
+		}
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	f := fake.NewInjector(now)
+	verifier := publish(t, f)
+
+	// Replace the second variant with another kitten, keeping its metadata.
+	obj, _ := lo.Find(f.Store.Objects(), func(o fake.StoredObject) bool { return o.Name == "20231201-2.png" })
+	obj.Data = lo.Must(fake.Image("another kitten"))
+	f.Store.Put(obj.UploadParams)
+
+	report, err := verifier.Verify(context.Background(), "20231201")
+	if !errors.Is(err, verify.ErrNotVerified) {
+		t.Fatalf("got %v, want ErrNotVerified", err)
+	}
+	if r := report.Results[0]; !r.Reproduced || r.Tampered {
+		t.Errorf("got %+v for the untouched variant", r)
+	}
+	if r := report.Results[1]; r.Reproduced || !r.Tampered || r.Similarity >= 0.9 {
+		t.Errorf("got %+v for the replaced variant", r)
+	}
+}
+
+func TestVerifyMissingDay(t *testing.T) {
+	verifier := do.MustInvoke[*verify.Verifier](fake.NewInjector(now).Build())
+	if _, err := verifier.Verify(context.Background(), "20231130"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
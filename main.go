@@ -3,37 +3,87 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	_ "time/tzdata"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/dmorgan81/kittenbot/internal/config"
 	"github.com/dmorgan81/kittenbot/internal/handler"
 	"github.com/dmorgan81/kittenbot/internal/inject"
 	"github.com/dmorgan81/kittenbot/internal/log"
+	"github.com/dmorgan81/kittenbot/internal/schedule"
+	"github.com/dmorgan81/kittenbot/internal/server"
+	"github.com/dmorgan81/kittenbot/internal/verify"
 	"github.com/samber/do"
+	"golang.org/x/sync/errgroup"
 )
 
 func main() {
 	ctx := log.NewContext(context.Background(), log.New(os.Stderr))
 	ctx, cancel := context.WithCancel(ctx)
 
-	injector := inject.Setup(ctx)
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	injector := inject.Setup(ctx, cfg)
 
 	if _, ok := os.LookupEnv("AWS_LAMBDA_RUNTIME_API"); ok {
-		handler := do.MustInvoke[*handler.Handler](injector).Handle
-		go lambda.StartWithOptions(handler, lambda.WithContext(ctx), lambda.WithEnableSIGTERM(func() {
-			cancel()
-		}))
-	} else {
-		var input handler.Input
-		if err := json.NewDecoder(os.Stdin).Decode(&input); err != nil {
+		handler, err := do.Invoke[*handler.Handler](injector)
+		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		go lambda.StartWithOptions(handler.Handle, lambda.WithContext(ctx), lambda.WithEnableSIGTERM(func() {
+			cancel()
+		}))
+	} else if cfg.Listen != "" || cfg.Schedule != "" {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		group, ctx := errgroup.WithContext(ctx)
+
+		if cfg.Listen != "" {
+			server, err := do.Invoke[*server.Server](injector)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			group.Go(func() error {
+				return server.ListenAndServe(ctx, cfg.Listen)
+			})
+		}
+		if cfg.Schedule != "" {
+			scheduler, err := do.Invoke[*schedule.Scheduler](injector)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			group.Go(func() error {
+				return scheduler.Run(ctx)
+			})
+		}
 
-		handler := do.MustInvoke[*handler.Handler](injector)
-		output, err := handler.Handle(ctx, input)
+		go func() {
+			defer cancel()
+			defer stop()
+			if err := group.Wait(); err != nil {
+				fmt.Println(err)
+			}
+		}()
+	} else {
+		output, err := run(ctx, injector, args)
 		if err != nil {
+			// A failed verification still reports how each image compared; any other
+			// failure has no output worth printing.
+			if errors.Is(err, verify.ErrNotVerified) {
+				fmt.Println(output)
+			}
 			fmt.Println(err)
 			os.Exit(1)
 		}
@@ -49,3 +99,39 @@ func main() {
 		}
 	}
 }
+
+// run performs a single invocation: a run read from stdin, listing, approving or rejecting
+// staged runs, or verifying a published day. A failed verification returns its report too.
+func run(ctx context.Context, injector *do.Injector, args []string) (any, error) {
+	h, err := do.Invoke[*handler.Handler](injector)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(args) == 0:
+		var input handler.Input
+		if err := json.NewDecoder(os.Stdin).Decode(&input); err != nil {
+			return nil, err
+		}
+		return h.Handle(ctx, input)
+	case args[0] == "pending" && len(args) == 1:
+		return h.Pending(ctx)
+	case args[0] == "approve" && len(args) == 2:
+		return h.Approve(ctx, args[1])
+	case args[0] == "reject" && len(args) == 2:
+		return h.Reject(ctx, args[1])
+	case args[0] == "verify" && len(args) == 2:
+		verifier, err := do.Invoke[*verify.Verifier](injector)
+		if err != nil {
+			return nil, err
+		}
+		report, err := verifier.Verify(ctx, args[1])
+		if err != nil && !errors.Is(err, verify.ErrNotVerified) {
+			return nil, err
+		}
+		return report, err
+	default:
+		return nil, fmt.Errorf("unknown command %q, usage: kittenbot [flags] [pending | approve YYYYMMDD | reject YYYYMMDD | verify YYYYMMDD]", strings.Join(args, " "))
+	}
+}